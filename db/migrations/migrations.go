@@ -0,0 +1,8 @@
+// Package migrations embeds the SQL migrations into the service binary,
+// one directory per database driver
+package migrations
+
+import "embed"
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var FS embed.FS
@@ -0,0 +1,4 @@
+-- Intentionally a no-op: narrowing the column back to varchar(72) would
+-- fail on, or in non-strict mode truncate, any argon2id hash stored since
+-- the up migration. A wider column is harmless to the previous version.
+DO 0;
@@ -0,0 +1 @@
+ALTER TABLE `users` MODIFY `password` varchar(255) NOT NULL;
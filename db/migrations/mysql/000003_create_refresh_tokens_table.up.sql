@@ -0,0 +1,11 @@
+CREATE TABLE `refresh_tokens` (
+    `id` bigint(20) AUTO_INCREMENT PRIMARY KEY,
+    `user_id` bigint(20) NOT NULL,
+    `token_hash` char(64) NOT NULL UNIQUE,
+    `family_id` varchar(32) NOT NULL,
+    `expires_at` datetime NOT NULL,
+    `revoked_at` datetime NULL,
+    `created_at` datetime DEFAULT CURRENT_TIMESTAMP,
+    KEY `idx_refresh_tokens_family_id` (`family_id`),
+    CONSTRAINT `fk_refresh_tokens_user_id` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+);
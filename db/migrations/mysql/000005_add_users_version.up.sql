@@ -0,0 +1 @@
+ALTER TABLE `users` ADD COLUMN `version` bigint unsigned NOT NULL DEFAULT 1 AFTER `role`;
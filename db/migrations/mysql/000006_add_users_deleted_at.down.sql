@@ -0,0 +1,10 @@
+-- Fails if a soft-deleted user shares an email or username with another
+-- user; purge or rename those rows first.
+ALTER TABLE `users`
+    DROP INDEX `users_email_unique`,
+    DROP INDEX `users_username_unique`,
+    DROP INDEX `users_deleted_at_idx`,
+    ADD UNIQUE INDEX `email` (`email`),
+    ADD UNIQUE INDEX `username` (`username`);
+
+ALTER TABLE `users` DROP COLUMN `not_deleted`, DROP COLUMN `deleted_at`;
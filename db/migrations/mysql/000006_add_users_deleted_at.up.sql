@@ -0,0 +1,12 @@
+ALTER TABLE `users` ADD COLUMN `deleted_at` datetime NULL DEFAULT NULL AFTER `updated_at`;
+
+-- NULL for soft-deleted rows, which unique indexes never consider equal,
+-- so a deleted account does not block its email or username.
+ALTER TABLE `users` ADD COLUMN `not_deleted` tinyint GENERATED ALWAYS AS (IF(`deleted_at` IS NULL, 1, NULL)) VIRTUAL;
+
+ALTER TABLE `users`
+    DROP INDEX `email`,
+    DROP INDEX `username`,
+    ADD UNIQUE INDEX `users_email_unique` (`email`, `not_deleted`),
+    ADD UNIQUE INDEX `users_username_unique` (`username`, `not_deleted`),
+    ADD INDEX `users_deleted_at_idx` (`deleted_at`);
@@ -0,0 +1,17 @@
+CREATE TABLE users (
+    id         bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    email      varchar(100) NOT NULL,
+    username   varchar(100) NOT NULL,
+    password   varchar(255) NOT NULL,
+    role       varchar(20)  NOT NULL DEFAULT 'user',
+    version    bigint       NOT NULL DEFAULT 1,
+    created_at timestamptz  NOT NULL DEFAULT NOW(),
+    updated_at timestamptz  NOT NULL DEFAULT NOW(),
+    deleted_at timestamptz  NULL
+);
+
+-- Partial indexes ignore soft-deleted rows, so a deleted account does not
+-- block its email or username.
+CREATE UNIQUE INDEX users_email_unique ON users (email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX users_username_unique ON users (username) WHERE deleted_at IS NULL;
+CREATE INDEX users_deleted_at_idx ON users (deleted_at);
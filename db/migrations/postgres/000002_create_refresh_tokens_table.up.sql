@@ -0,0 +1,12 @@
+CREATE TABLE refresh_tokens (
+    id         bigint GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id    bigint      NOT NULL,
+    token_hash char(64)    NOT NULL UNIQUE,
+    family_id  varchar(32) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz NULL,
+    created_at timestamptz NOT NULL DEFAULT NOW(),
+    CONSTRAINT fk_refresh_tokens_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
@@ -0,0 +1,25 @@
+-- SQLite ignores varchar lengths, so the CHECK constraints enforce the
+-- limits the other databases get from the column types. Timestamps are
+-- stored as UTC text with millisecond precision, which sorts and compares
+-- correctly.
+CREATE TABLE users (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    email      varchar(100) NOT NULL,
+    username   varchar(100) NOT NULL,
+    password   varchar(255) NOT NULL,
+    role       varchar(20)  NOT NULL DEFAULT 'user',
+    version    INTEGER      NOT NULL DEFAULT 1,
+    created_at datetime     NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at datetime     NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    deleted_at datetime     NULL,
+    CONSTRAINT users_email_length CHECK (length(email) <= 100),
+    CONSTRAINT users_username_length CHECK (length(username) <= 100),
+    CONSTRAINT users_password_length CHECK (length(password) <= 255),
+    CONSTRAINT users_role_length CHECK (length(role) <= 20)
+);
+
+-- Partial indexes ignore soft-deleted rows, so a deleted account does not
+-- block its email or username.
+CREATE UNIQUE INDEX users_email_unique ON users (email) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX users_username_unique ON users (username) WHERE deleted_at IS NULL;
+CREATE INDEX users_deleted_at_idx ON users (deleted_at);
@@ -0,0 +1,11 @@
+CREATE TABLE refresh_tokens (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id    INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash char(64)    NOT NULL UNIQUE,
+    family_id  varchar(32) NOT NULL,
+    expires_at datetime    NOT NULL,
+    revoked_at datetime    NULL,
+    created_at datetime    NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
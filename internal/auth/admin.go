@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-crud/internal/config"
+	"go-crud/internal/domain"
+	"go-crud/internal/password"
+	"go-crud/internal/validation"
+	"log/slog"
+)
+
+// EnsureAdmin creates the configured initial admin unless a user with its
+// username exists. Only admins can grant roles, so without it a fresh
+// deployment has no way to get one. An existing user that is not an admin
+// is an error rather than being promoted, since anyone could have signed
+// up with the name.
+func EnsureAdmin(ctx context.Context, users domain.UserRepository, passwords *password.Service, admin config.AdminConfig, logger *slog.Logger) error {
+	if admin.Username == "" {
+		return nil
+	}
+
+	exists, err := adminExists(ctx, users, admin.Username)
+	if err != nil || exists {
+		return err
+	}
+
+	user := domain.User{
+		Username: admin.Username,
+		Email:    admin.Email,
+		Password: admin.Password,
+		Role:     domain.RoleAdmin,
+	}
+	if err := validation.User(&user); err != nil {
+		return fmt.Errorf("invalid initial admin: %w", err)
+	}
+	user.Password, err = passwords.Hash(user.Password)
+	if err != nil {
+		return fmt.Errorf("failed to hash initial admin password: %w", err)
+	}
+
+	err = users.Create(ctx, &user)
+	if errors.Is(err, domain.ErrAlreadyExists) {
+		// Another instance may have created it meanwhile.
+		if exists, lookupErr := adminExists(ctx, users, admin.Username); lookupErr == nil && exists {
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create initial admin: %w", err)
+	}
+
+	logger.InfoContext(ctx, "created initial admin", "user_id", user.ID, "username", user.Username)
+	return nil
+}
+
+// adminExists reports whether the user named username exists, failing
+// when it is not an admin.
+func adminExists(ctx context.Context, users domain.UserRepository, username string) (bool, error) {
+	user, err := users.GetByLogin(ctx, username)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return false, nil
+	case err != nil:
+		return false, fmt.Errorf("failed to look up initial admin: %w", err)
+	case user.Role != domain.RoleAdmin:
+		return false, fmt.Errorf("initial admin %q exists with role %q", username, user.Role)
+	}
+	return true, nil
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"go-crud/internal/config"
+	"go-crud/internal/domain"
+	"go-crud/internal/logging"
+	"go-crud/internal/password"
+	"go-crud/internal/repository"
+	"testing"
+)
+
+func TestEnsureAdmin(t *testing.T) {
+	ctx := context.Background()
+	passwords := password.NewService(password.NewBcryptHasher(4))
+	admin := config.AdminConfig{Username: "root", Email: "root@email.com", Password: "password123"}
+
+	subtests := []struct {
+		name     string
+		existing *domain.User
+		admin    config.AdminConfig
+		wantErr  bool
+	}{
+		{name: "created", admin: admin},
+		{
+			name:     "already an admin",
+			existing: &domain.User{Username: "root", Email: "other@email.com", Password: "hash", Role: domain.RoleAdmin},
+			admin:    admin,
+		},
+		{
+			name:     "existing user is not promoted",
+			existing: &domain.User{Username: "root", Email: "other@email.com", Password: "hash", Role: domain.RoleUser},
+			admin:    admin,
+			wantErr:  true,
+		},
+		{
+			name:     "email taken",
+			existing: &domain.User{Username: "someone", Email: "root@email.com", Password: "hash", Role: domain.RoleUser},
+			admin:    admin,
+			wantErr:  true,
+		},
+		{
+			name:    "invalid",
+			admin:   config.AdminConfig{Username: "root", Email: "root@email.com", Password: "short"},
+			wantErr: true,
+		},
+		{name: "disabled"},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			users := repository.NewMemoryStore().Repositories().Users
+			if subtest.existing != nil {
+				if err := users.Create(ctx, subtest.existing); err != nil {
+					t.Fatalf("failed to create user: %v", err)
+				}
+			}
+
+			err := EnsureAdmin(ctx, users, passwords, subtest.admin, logging.Discard())
+			if (err != nil) != subtest.wantErr {
+				t.Fatalf("expected error: %v, got: %v", subtest.wantErr, err)
+			}
+			if subtest.wantErr || subtest.admin.Username == "" {
+				return
+			}
+
+			user, err := users.GetByLogin(ctx, "root")
+			if err != nil {
+				t.Fatalf("expected the admin to exist, got: %v", err)
+			}
+			if user.Role != domain.RoleAdmin {
+				t.Errorf("expected role: %v, got: %v", domain.RoleAdmin, user.Role)
+			}
+			if subtest.existing == nil {
+				if _, err := passwords.Verify(user.Password, admin.Password); err != nil {
+					t.Errorf("expected the configured password to be hashed, got: %v", err)
+				}
+			}
+		})
+	}
+
+	t.Run("idempotent", func(t *testing.T) {
+		users := repository.NewMemoryStore().Repositories().Users
+		for range 2 {
+			if err := EnsureAdmin(ctx, users, passwords, admin, logging.Discard()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		list, err := users.List(ctx, domain.UserListParams{Limit: 10})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(list.Users) != 1 {
+			t.Errorf("expected 1 user, got: %d", len(list.Users))
+		}
+	})
+
+	t.Run("lookup failure", func(t *testing.T) {
+		lookupErr := errors.New("connection lost")
+		err := EnsureAdmin(ctx, failingUserRepo{err: lookupErr}, passwords, admin, logging.Discard())
+		if !errors.Is(err, lookupErr) {
+			t.Errorf("expected error: %v, got: %v", lookupErr, err)
+		}
+	})
+}
+
+type failingUserRepo struct {
+	domain.UserRepository
+	err error
+}
+
+func (f failingUserRepo) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
+	return nil, f.err
+}
@@ -0,0 +1,25 @@
+// Package auth issues and verifies access and refresh tokens
+package auth
+
+import (
+	"context"
+	"go-crud/internal/domain"
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID   int64
+	Username string
+	Role     domain.Role
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
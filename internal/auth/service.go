@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-crud/internal/domain"
+	"go-crud/internal/password"
+	"log/slog"
+	"time"
+)
+
+const refreshTokenBytes = 32
+
+type TokenPair struct {
+	AccessToken           string
+	AccessTokenExpiresAt  time.Time
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
+}
+
+// Service implements login, refresh token rotation and logout.
+type Service struct {
+	users         domain.UserRepository
+	refreshTokens domain.RefreshTokenRepository
+	tx            domain.TxManager
+	passwords     *password.Service
+	tokens        *TokenManager
+	refreshTTL    time.Duration
+	logger        *slog.Logger
+	now           func() time.Time
+	// dummyHash is verified against when the login is unknown so that
+	// response times do not reveal which usernames exist.
+	dummyHash string
+}
+
+func NewService(
+	repos domain.Repositories,
+	tx domain.TxManager,
+	passwords *password.Service,
+	tokens *TokenManager,
+	refreshTTL time.Duration,
+	logger *slog.Logger,
+) (*Service, error) {
+	dummyHash, err := passwords.Hash("dummy-password-0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare dummy hash: %w", err)
+	}
+
+	return &Service{
+		users:         repos.Users,
+		refreshTokens: repos.RefreshTokens,
+		tx:            tx,
+		passwords:     passwords,
+		tokens:        tokens,
+		refreshTTL:    refreshTTL,
+		logger:        logger,
+		now:           time.Now,
+		dummyHash:     dummyHash,
+	}, nil
+}
+
+// Login checks the password of the user identified by username or email
+// and starts a new refresh token family.
+func (s *Service) Login(ctx context.Context, login, plain string) (*TokenPair, error) {
+	user, err := s.users.GetByLogin(ctx, login)
+	if errors.Is(err, domain.ErrNotFound) {
+		s.passwords.Verify(s.dummyHash, plain)
+		return nil, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rehashed, err := s.passwords.Verify(user.Password, plain)
+	if errors.Is(err, password.ErrMismatch) {
+		return nil, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if rehashed != "" {
+		// Only the version that was verified is rehashed; if the user
+		// changed meanwhile, e.g. got a new password, the rehash is
+		// skipped and happens on the next login.
+		err := s.users.Update(ctx, user.ID, &domain.UserUpdate{Password: &rehashed, Version: &user.Version})
+		switch {
+		case errors.Is(err, domain.ErrConflict):
+			s.logger.DebugContext(ctx, "skipped rehash of a changed user", "user_id", user.ID)
+		case err != nil:
+			s.logger.WarnContext(ctx, "failed to store rehashed password", "user_id", user.ID, "error", err)
+		}
+	}
+
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(ctx, s.refreshTokens, user, familyID)
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// token is revoked; presenting an already revoked token is treated as
+// theft and revokes every token of its family.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	stored, err := s.refreshTokens.GetByHash(ctx, hashToken(refreshToken))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.RevokedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, stored)
+	}
+	if !s.now().Before(stored.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+
+	// Revoking the presented token and storing its successor happen in one
+	// transaction so a failure in between does not leave the session
+	// without a valid refresh token.
+	var pair *TokenPair
+	err = s.tx.WithinTx(ctx, func(ctx context.Context, repos domain.Repositories) error {
+		// Revoke is a compare-and-swap, so of two concurrent refreshes
+		// with the same token only one wins.
+		if err := repos.RefreshTokens.Revoke(ctx, stored.ID); err != nil {
+			return err
+		}
+
+		user, err := repos.Users.GetByID(ctx, stored.UserID)
+		if errors.Is(err, domain.ErrNotFound) {
+			return ErrInvalidToken
+		}
+		if err != nil {
+			return err
+		}
+
+		pair, err = s.issue(ctx, repos.RefreshTokens, user, stored.FamilyID)
+		return err
+	})
+	// Only Revoke reports ErrNotFound: the token was revoked concurrently.
+	// The family is revoked outside the rolled back transaction.
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, s.revokeReusedFamily(ctx, stored)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Logout revokes every refresh token of the session the token belongs to.
+// Unknown tokens are ignored so logout is idempotent.
+func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+	stored, err := s.refreshTokens.GetByHash(ctx, hashToken(refreshToken))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.refreshTokens.RevokeFamily(ctx, stored.FamilyID)
+}
+
+func (s *Service) issue(ctx context.Context, refreshTokens domain.RefreshTokenRepository, user *domain.User, familyID string) (*TokenPair, error) {
+	accessToken, accessExpiresAt, err := s.tokens.Issue(Principal{UserID: user.ID, Username: user.Username, Role: user.Role})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken(refreshTokenBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := &domain.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(refreshToken),
+		FamilyID:  familyID,
+		ExpiresAt: s.now().Add(s.refreshTTL).UTC(),
+	}
+	if err := refreshTokens.Create(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: stored.ExpiresAt,
+	}, nil
+}
+
+func (s *Service) revokeReusedFamily(ctx context.Context, stored *domain.RefreshToken) error {
+	if err := s.refreshTokens.RevokeFamily(ctx, stored.FamilyID); err != nil {
+		return err
+	}
+	return ErrInvalidToken
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
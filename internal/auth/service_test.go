@@ -0,0 +1,293 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"go-crud/internal/domain"
+	"go-crud/internal/logging"
+	"go-crud/internal/password"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeUserRepo struct {
+	domain.UserRepository
+	user      *domain.User
+	updates   []*domain.UserUpdate
+	updateErr error
+}
+
+func (f *fakeUserRepo) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
+	if login == f.user.Username || login == f.user.Email {
+		u := *f.user
+		return &u, nil
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (f *fakeUserRepo) GetByID(ctx context.Context, id int64) (*domain.User, error) {
+	if id == f.user.ID {
+		u := *f.user
+		return &u, nil
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (f *fakeUserRepo) Update(ctx context.Context, id int64, upd *domain.UserUpdate) error {
+	f.updates = append(f.updates, upd)
+	return f.updateErr
+}
+
+type fakeRefreshTokenRepo struct {
+	mu     sync.Mutex
+	tokens []*domain.RefreshToken
+}
+
+func (f *fakeRefreshTokenRepo) Create(ctx context.Context, token *domain.RefreshToken) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	token.ID = int64(len(f.tokens) + 1)
+	stored := *token
+	f.tokens = append(f.tokens, &stored)
+	return nil
+}
+
+func (f *fakeRefreshTokenRepo) GetByHash(ctx context.Context, hash string) (*domain.RefreshToken, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, t := range f.tokens {
+		if t.TokenHash == hash {
+			stored := *t
+			return &stored, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (f *fakeRefreshTokenRepo) Revoke(ctx context.Context, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, t := range f.tokens {
+		if t.ID == id && t.RevokedAt == nil {
+			now := time.Now()
+			t.RevokedAt = &now
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (f *fakeRefreshTokenRepo) RevokeFamily(ctx context.Context, familyID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	for _, t := range f.tokens {
+		if t.FamilyID == familyID && t.RevokedAt == nil {
+			t.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (f *fakeRefreshTokenRepo) activeCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, t := range f.tokens {
+		if t.RevokedAt == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// fakeTxManager runs units of work directly on the fake repositories.
+type fakeTxManager struct {
+	repos domain.Repositories
+}
+
+func (f fakeTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context, repos domain.Repositories) error) error {
+	return fn(ctx, f.repos)
+}
+
+func newTestService(t *testing.T, passwords *password.Service, hash string) (*Service, *fakeUserRepo, *fakeRefreshTokenRepo) {
+	t.Helper()
+	users := &fakeUserRepo{user: &domain.User{ID: 1, Username: "testuser", Email: "test@email.com", Password: hash, Role: domain.RoleUser, Version: 3}}
+	refreshTokens := &fakeRefreshTokenRepo{}
+	tokens := NewTokenManager(testHMACKey("test"), "test", time.Minute)
+
+	repos := domain.Repositories{Users: users, RefreshTokens: refreshTokens}
+	svc, err := NewService(repos, fakeTxManager{repos: repos}, passwords, tokens, time.Hour, logging.Discard())
+	if err != nil {
+		t.Fatalf("failed to create service: %v", err)
+	}
+	return svc, users, refreshTokens
+}
+
+func TestService_Login(t *testing.T) {
+	passwords := password.NewService(password.NewBcryptHasher(4))
+	hash, err := passwords.Hash("password123")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	subtests := []struct {
+		name        string
+		login       string
+		password    string
+		expectedErr error
+	}{
+		{name: "by username", login: "testuser", password: "password123"},
+		{name: "by email", login: "test@email.com", password: "password123"},
+		{name: "wrong password", login: "testuser", password: "wrong", expectedErr: domain.ErrInvalidCredentials},
+		{name: "unknown user", login: "nobody", password: "password123", expectedErr: domain.ErrInvalidCredentials},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			svc, _, refreshTokens := newTestService(t, passwords, hash)
+
+			pair, err := svc.Login(context.Background(), subtest.login, subtest.password)
+			if !errors.Is(err, subtest.expectedErr) {
+				t.Fatalf("expected error: %v, got: %v", subtest.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			principal, err := svc.tokens.Parse(pair.AccessToken)
+			if err != nil || principal.UserID != 1 {
+				t.Errorf("expected access token for user 1, got: %+v, %v", principal, err)
+			}
+			if refreshTokens.activeCount() != 1 {
+				t.Errorf("expected one stored refresh token, got: %d", refreshTokens.activeCount())
+			}
+		})
+	}
+}
+
+func TestService_LoginRehashes(t *testing.T) {
+	hash, err := password.NewService(password.NewBcryptHasher(4)).Hash("password123")
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+
+	subtests := []struct {
+		name      string
+		updateErr error
+	}{
+		{name: "stored"},
+		// The user changed since it was read; the login still succeeds.
+		{name: "skipped on conflict", updateErr: domain.ErrConflict},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			svc, users, _ := newTestService(t, password.NewService(password.NewBcryptHasher(5)), hash)
+			users.updateErr = subtest.updateErr
+
+			if _, err := svc.Login(context.Background(), "testuser", "password123"); err != nil {
+				t.Fatalf("unexpected login error: %v", err)
+			}
+			if len(users.updates) != 1 || users.updates[0].Password == nil || *users.updates[0].Password == hash {
+				t.Fatalf("expected rehashed password to be stored, got: %+v", users.updates)
+			}
+			if v := users.updates[0].Version; v == nil || *v != users.user.Version {
+				t.Errorf("expected the rehash to expect version %d, got: %v", users.user.Version, v)
+			}
+		})
+	}
+}
+
+func TestService_LoginLegacyPlaintext(t *testing.T) {
+	passwords := password.NewService(password.NewBcryptHasher(4))
+	svc, users, _ := newTestService(t, passwords, "password123")
+
+	if _, err := svc.Login(context.Background(), "testuser", "wrong"); !errors.Is(err, domain.ErrInvalidCredentials) {
+		t.Fatalf("expected error: %v, got: %v", domain.ErrInvalidCredentials, err)
+	}
+	if _, err := svc.Login(context.Background(), "testuser", "password123"); err != nil {
+		t.Fatalf("unexpected login error: %v", err)
+	}
+	if len(users.updates) != 1 || users.updates[0].Password == nil {
+		t.Fatalf("expected the password to be hashed, got: %+v", users.updates)
+	}
+	if _, err := passwords.Verify(*users.updates[0].Password, "password123"); err != nil {
+		t.Errorf("expected the stored hash to verify, got: %v", err)
+	}
+}
+
+func TestService_RefreshRotation(t *testing.T) {
+	passwords := password.NewService(password.NewBcryptHasher(4))
+	hash, _ := passwords.Hash("password123")
+	svc, _, refreshTokens := newTestService(t, passwords, hash)
+	ctx := context.Background()
+
+	first, err := svc.Login(ctx, "testuser", "password123")
+	if err != nil {
+		t.Fatalf("unexpected login error: %v", err)
+	}
+
+	second, err := svc.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("unexpected refresh error: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("expected refresh token to rotate")
+	}
+
+	// Replaying the rotated token revokes the whole family, including
+	// the token issued by the legitimate refresh.
+	if _, err := svc.Refresh(ctx, first.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected error: %v, got: %v", ErrInvalidToken, err)
+	}
+	if _, err := svc.Refresh(ctx, second.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected family to be revoked, got: %v", err)
+	}
+	if refreshTokens.activeCount() != 0 {
+		t.Errorf("expected no active refresh tokens, got: %d", refreshTokens.activeCount())
+	}
+}
+
+func TestService_RefreshExpired(t *testing.T) {
+	passwords := password.NewService(password.NewBcryptHasher(4))
+	hash, _ := passwords.Hash("password123")
+	svc, _, _ := newTestService(t, passwords, hash)
+	ctx := context.Background()
+
+	pair, err := svc.Login(ctx, "testuser", "password123")
+	if err != nil {
+		t.Fatalf("unexpected login error: %v", err)
+	}
+
+	svc.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, err := svc.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected error: %v, got: %v", ErrInvalidToken, err)
+	}
+}
+
+func TestService_Logout(t *testing.T) {
+	passwords := password.NewService(password.NewBcryptHasher(4))
+	hash, _ := passwords.Hash("password123")
+	svc, _, refreshTokens := newTestService(t, passwords, hash)
+	ctx := context.Background()
+
+	pair, err := svc.Login(ctx, "testuser", "password123")
+	if err != nil {
+		t.Fatalf("unexpected login error: %v", err)
+	}
+
+	if err := svc.Logout(ctx, pair.RefreshToken); err != nil {
+		t.Fatalf("unexpected logout error: %v", err)
+	}
+	if err := svc.Logout(ctx, "unknown"); err != nil {
+		t.Errorf("expected logout of unknown token to succeed, got: %v", err)
+	}
+	if refreshTokens.activeCount() != 0 {
+		t.Errorf("expected no active refresh tokens, got: %d", refreshTokens.activeCount())
+	}
+	if _, err := svc.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected error: %v, got: %v", ErrInvalidToken, err)
+	}
+}
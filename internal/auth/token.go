@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"fmt"
+	"go-crud/internal/config"
+	"go-crud/internal/domain"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = fmt.Errorf("%w: invalid or expired token", domain.ErrUnauthorized)
+
+// Key is a JWT signing or verification key identified by its key ID.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// Sign is the HMAC secret or private key; it is nil for keys that
+	// only verify tokens.
+	Sign   any
+	Verify any
+}
+
+type accessClaims struct {
+	Username string      `json:"username"`
+	Role     domain.Role `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// TokenManager signs access tokens with a single active key and verifies
+// them with any known key, selected by the "kid" header.
+type TokenManager struct {
+	signing Key
+	keys    map[string]Key
+	issuer  string
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+func NewTokenManager(signing Key, issuer string, ttl time.Duration, verifyOnly ...Key) *TokenManager {
+	keys := map[string]Key{signing.ID: signing}
+	for _, k := range verifyOnly {
+		keys[k.ID] = k
+	}
+	return &TokenManager{
+		signing: signing,
+		keys:    keys,
+		issuer:  issuer,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+func NewTokenManagerFromConfig(cfg config.AuthConfig) (*TokenManager, error) {
+	signing, err := loadSigningKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var verifyOnly []Key
+	for kid, path := range cfg.PublicKeyFiles {
+		key, err := loadPublicKey(kid, path)
+		if err != nil {
+			return nil, err
+		}
+		verifyOnly = append(verifyOnly, key)
+	}
+
+	return NewTokenManager(signing, cfg.Issuer, cfg.AccessTokenTTL, verifyOnly...), nil
+}
+
+func (m *TokenManager) TTL() time.Duration {
+	return m.ttl
+}
+
+func (m *TokenManager) Issue(p Principal) (string, time.Time, error) {
+	now := m.now()
+	expiresAt := now.Add(m.ttl)
+
+	token := jwt.NewWithClaims(m.signing.Method, accessClaims{
+		Username: p.Username,
+		Role:     p.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    m.issuer,
+			Subject:   strconv.FormatInt(p.UserID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	})
+	token.Header["kid"] = m.signing.ID
+
+	signed, err := token.SignedString(m.signing.Sign)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign access token: %w", err)
+	}
+	return signed, expiresAt, nil
+}
+
+func (m *TokenManager) Parse(tokenString string) (*Principal, error) {
+	var claims accessClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, m.keyFunc,
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(m.now),
+	)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || !claims.Role.Valid() {
+		return nil, ErrInvalidToken
+	}
+
+	return &Principal{UserID: userID, Username: claims.Username, Role: claims.Role}, nil
+}
+
+func (m *TokenManager) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := m.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	// Pinning the method to the key prevents algorithm confusion, e.g. an
+	// HS256 token "signed" with an RSA public key.
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %q", token.Method.Alg())
+	}
+	return key.Verify, nil
+}
+
+func loadSigningKey(cfg config.AuthConfig) (Key, error) {
+	switch cfg.SigningAlgorithm {
+	case "HS256":
+		if len(cfg.HMACSecret) < config.MinHMACSecretLength {
+			return Key{}, fmt.Errorf("HS256 secret must be at least %d bytes", config.MinHMACSecretLength)
+		}
+		secret := []byte(cfg.HMACSecret)
+		return Key{ID: cfg.KeyID, Method: jwt.SigningMethodHS256, Sign: secret, Verify: secret}, nil
+	case "RS256":
+		pem, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return Key{}, fmt.Errorf("failed to read private key: %w", err)
+		}
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return Key{}, fmt.Errorf("failed to parse RSA private key: %w", err)
+		}
+		return Key{ID: cfg.KeyID, Method: jwt.SigningMethodRS256, Sign: private, Verify: &private.PublicKey}, nil
+	case "EdDSA":
+		pem, err := os.ReadFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return Key{}, fmt.Errorf("failed to read private key: %w", err)
+		}
+		private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return Key{}, fmt.Errorf("failed to parse Ed25519 private key: %w", err)
+		}
+		public := private.(crypto.Signer).Public()
+		return Key{ID: cfg.KeyID, Method: jwt.SigningMethodEdDSA, Sign: private, Verify: public}, nil
+	default:
+		return Key{}, fmt.Errorf("unsupported signing algorithm %q", cfg.SigningAlgorithm)
+	}
+}
+
+func loadPublicKey(kid, path string) (Key, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, fmt.Errorf("failed to read public key %q: %w", kid, err)
+	}
+
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return Key{ID: kid, Method: jwt.SigningMethodRS256, Verify: public}, nil
+	}
+	if public, err := jwt.ParseEdPublicKeyFromPEM(pem); err == nil {
+		if _, ok := public.(ed25519.PublicKey); ok {
+			return Key{ID: kid, Method: jwt.SigningMethodEdDSA, Verify: public}, nil
+		}
+	}
+	return Key{}, fmt.Errorf("public key %q is neither RSA nor Ed25519", kid)
+}
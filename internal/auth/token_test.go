@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"go-crud/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testHMACKey(id string) Key {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	return Key{ID: id, Method: jwt.SigningMethodHS256, Sign: secret, Verify: secret}
+}
+
+func TestTokenManager_IssueAndParse(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate Ed25519 key: %v", err)
+	}
+
+	subtests := []struct {
+		name string
+		key  Key
+	}{
+		{name: "HS256", key: testHMACKey("hmac")},
+		{name: "RS256", key: Key{ID: "rsa", Method: jwt.SigningMethodRS256, Sign: rsaKey, Verify: &rsaKey.PublicKey}},
+		{name: "EdDSA", key: Key{ID: "ed", Method: jwt.SigningMethodEdDSA, Sign: edPrivate, Verify: edPublic}},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			m := NewTokenManager(subtest.key, "test", time.Minute)
+
+			token, expiresAt, err := m.Issue(Principal{UserID: 42, Username: "testuser", Role: domain.RoleUser})
+			if err != nil {
+				t.Fatalf("unexpected issue error: %v", err)
+			}
+			if time.Until(expiresAt) > time.Minute {
+				t.Errorf("expected expiry within a minute, got: %v", expiresAt)
+			}
+
+			principal, err := m.Parse(token)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			if principal.UserID != 42 || principal.Username != "testuser" {
+				t.Errorf("unexpected principal: %+v", principal)
+			}
+		})
+	}
+}
+
+func TestTokenManager_ParseRejects(t *testing.T) {
+	m := NewTokenManager(testHMACKey("current"), "test", time.Minute)
+	valid, _, err := m.Issue(Principal{UserID: 1, Role: domain.RoleUser})
+	if err != nil {
+		t.Fatalf("unexpected issue error: %v", err)
+	}
+
+	expired := NewTokenManager(testHMACKey("current"), "test", time.Minute)
+	expired.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	expiredToken, _, _ := expired.Issue(Principal{UserID: 1, Role: domain.RoleUser})
+
+	otherIssuer := NewTokenManager(testHMACKey("current"), "other", time.Minute)
+	otherIssuerToken, _, _ := otherIssuer.Issue(Principal{UserID: 1, Role: domain.RoleUser})
+
+	unknownKey := NewTokenManager(testHMACKey("retired"), "test", time.Minute)
+	unknownKeyToken, _, _ := unknownKey.Issue(Principal{UserID: 1, Role: domain.RoleUser})
+
+	subtests := []struct {
+		name  string
+		token string
+	}{
+		{name: "garbage", token: "not-a-token"},
+		{name: "tampered", token: valid[:len(valid)-2] + "xx"},
+		{name: "expired", token: expiredToken},
+		{name: "other issuer", token: otherIssuerToken},
+		{name: "unknown key id", token: unknownKeyToken},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if _, err := m.Parse(subtest.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("expected error: %v, got: %v", ErrInvalidToken, err)
+			}
+		})
+	}
+}
+
+func TestTokenManager_VerifyOnlyKeys(t *testing.T) {
+	retired := NewTokenManager(testHMACKey("retired"), "test", time.Minute)
+	token, _, err := retired.Issue(Principal{UserID: 7, Role: domain.RoleUser})
+	if err != nil {
+		t.Fatalf("unexpected issue error: %v", err)
+	}
+
+	retiredKey := testHMACKey("retired")
+	retiredKey.Sign = nil
+	m := NewTokenManager(testHMACKey("current"), "test", time.Minute, retiredKey)
+
+	principal, err := m.Parse(token)
+	if err != nil {
+		t.Fatalf("expected token of retired key to verify, got: %v", err)
+	}
+	if principal.UserID != 7 {
+		t.Errorf("expected user id 7, got: %d", principal.UserID)
+	}
+}
+
+func TestTokenManager_AlgorithmConfusion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	m := NewTokenManager(Key{ID: "rsa", Method: jwt.SigningMethodRS256, Sign: rsaKey, Verify: &rsaKey.PublicKey}, "test", time.Minute)
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    "test",
+		Subject:   "1",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	})
+	forged.Header["kid"] = "rsa"
+	token, err := forged.SignedString([]byte("attacker-chosen-secret"))
+	if err != nil {
+		t.Fatalf("failed to sign forged token: %v", err)
+	}
+
+	if _, err := m.Parse(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected error: %v, got: %v", ErrInvalidToken, err)
+	}
+}
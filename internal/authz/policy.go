@@ -0,0 +1,81 @@
+// Package authz decides which user operations a principal may perform
+package authz
+
+import (
+	"go-crud/internal/auth"
+	"go-crud/internal/domain"
+)
+
+type Action string
+
+const (
+	ActionListUsers  Action = "users:list"
+	ActionReadUser   Action = "users:read"
+	ActionUpdateUser Action = "users:update"
+	ActionDeleteUser Action = "users:delete"
+	ActionChangeRole Action = "users:change_role"
+	// ActionRestoreUser undoes a soft delete.
+	ActionRestoreUser Action = "users:restore"
+	// ActionHardDeleteUser removes a user permanently.
+	ActionHardDeleteUser Action = "users:hard_delete"
+)
+
+// Scope limits the users an action may target.
+type Scope int
+
+const (
+	ScopeNone Scope = iota
+	// ScopeOwn allows the action only on the principal's own account.
+	ScopeOwn
+	ScopeAny
+)
+
+type Rules map[domain.Role]map[Action]Scope
+
+// DefaultRules let users manage their own account and admins manage
+// every account.
+var DefaultRules = Rules{
+	domain.RoleUser: {
+		ActionReadUser:   ScopeOwn,
+		ActionUpdateUser: ScopeOwn,
+		ActionDeleteUser: ScopeOwn,
+	},
+	domain.RoleAdmin: {
+		ActionListUsers:  ScopeAny,
+		ActionReadUser:   ScopeAny,
+		ActionUpdateUser: ScopeAny,
+		ActionDeleteUser: ScopeAny,
+		ActionChangeRole: ScopeAny,
+
+		ActionRestoreUser:    ScopeAny,
+		ActionHardDeleteUser: ScopeAny,
+	},
+}
+
+type Policy struct {
+	rules Rules
+}
+
+func NewPolicy(rules Rules) *Policy {
+	return &Policy{rules: rules}
+}
+
+// Authorize returns domain.ErrUnauthorized when there is no principal and
+// domain.ErrForbidden when the principal's role does not grant action on
+// the user identified by targetID. Pass 0 as targetID for actions that do
+// not target a single user.
+func (p *Policy) Authorize(principal *auth.Principal, action Action, targetID int64) error {
+	if principal == nil {
+		return domain.ErrUnauthorized
+	}
+
+	switch p.rules[principal.Role][action] {
+	case ScopeAny:
+		return nil
+	case ScopeOwn:
+		if targetID != 0 && targetID == principal.UserID {
+			return nil
+		}
+	}
+	return domain.ErrForbidden
+}
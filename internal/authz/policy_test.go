@@ -0,0 +1,46 @@
+package authz
+
+import (
+	"errors"
+	"go-crud/internal/auth"
+	"go-crud/internal/domain"
+	"testing"
+)
+
+func TestPolicy_Authorize(t *testing.T) {
+	policy := NewPolicy(DefaultRules)
+	user := &auth.Principal{UserID: 1, Role: domain.RoleUser}
+	admin := &auth.Principal{UserID: 2, Role: domain.RoleAdmin}
+	unknownRole := &auth.Principal{UserID: 3, Role: "guest"}
+
+	subtests := []struct {
+		name        string
+		principal   *auth.Principal
+		action      Action
+		targetID    int64
+		expectedErr error
+	}{
+		{name: "anonymous", principal: nil, action: ActionReadUser, targetID: 1, expectedErr: domain.ErrUnauthorized},
+		{name: "user reads self", principal: user, action: ActionReadUser, targetID: 1},
+		{name: "user updates self", principal: user, action: ActionUpdateUser, targetID: 1},
+		{name: "user deletes self", principal: user, action: ActionDeleteUser, targetID: 1},
+		{name: "user reads other", principal: user, action: ActionReadUser, targetID: 2, expectedErr: domain.ErrForbidden},
+		{name: "user deletes other", principal: user, action: ActionDeleteUser, targetID: 2, expectedErr: domain.ErrForbidden},
+		{name: "user lists users", principal: user, action: ActionListUsers, expectedErr: domain.ErrForbidden},
+		{name: "user changes own role", principal: user, action: ActionChangeRole, targetID: 1, expectedErr: domain.ErrForbidden},
+		{name: "admin reads other", principal: admin, action: ActionReadUser, targetID: 1},
+		{name: "admin deletes other", principal: admin, action: ActionDeleteUser, targetID: 1},
+		{name: "admin lists users", principal: admin, action: ActionListUsers},
+		{name: "admin changes role", principal: admin, action: ActionChangeRole, targetID: 1},
+		{name: "unknown role", principal: unknownRole, action: ActionReadUser, targetID: 3, expectedErr: domain.ErrForbidden},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			err := policy.Authorize(subtest.principal, subtest.action, subtest.targetID)
+			if !errors.Is(err, subtest.expectedErr) {
+				t.Errorf("expected error: %v, got: %v", subtest.expectedErr, err)
+			}
+		})
+	}
+}
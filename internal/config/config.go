@@ -1,30 +1,262 @@
 package config
 
 import (
-	"os"
+	"io"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Database drivers selectable with database.driver (DB_DRIVER).
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	// DriverMemory keeps all data in process memory and needs no
+	// database server; everything is lost on restart.
+	DriverMemory = "memory"
 )
 
+// Config is the complete service configuration. Load layers it from the
+// defaults, a config file, the environment and command-line flags.
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Logging   LoggingConfig   `yaml:"logging" toml:"logging"`
+	Migration MigrationConfig `yaml:"migration" toml:"migration"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	Purge     PurgeConfig     `yaml:"purge" toml:"purge"`
+	Tracing   TracingConfig   `yaml:"tracing" toml:"tracing"`
+	Password  PasswordConfig  `yaml:"password" toml:"password"`
+
+	// problems are the malformed values Load skipped.
+	problems []string
+}
+
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-}
-
-func LoadDatabaseConfig() DatabaseConfig {
-	return DatabaseConfig{
-		Host:     getEnv("DB_HOST", "127.0.0.1"),
-		Port:     getEnv("DB_PORT", "3307"),
-		User:     getEnv("DB_USER", "app_user"),
-		Password: getEnv("DB_PASSWORD", ""),
-		DBName:   getEnv("DB_NAME", "db_go_crud"),
+	// Driver is one of the Driver* constants.
+	Driver   string `yaml:"driver" toml:"driver"`
+	Host     string `yaml:"host" toml:"host"`
+	Port     string `yaml:"port" toml:"port"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	DBName   string `yaml:"name" toml:"name"`
+	// SSLMode is the PostgreSQL sslmode, ignored by the other drivers.
+	SSLMode string `yaml:"sslmode" toml:"sslmode"`
+	// Path is the SQLite database file. SQLite ignores the network
+	// settings above.
+	Path          string        `yaml:"path" toml:"path"`
+	QueryTimeouts QueryTimeouts `yaml:"query_timeouts" toml:"query_timeouts"`
+}
+
+// QueryOperations are the repository operations whose query timeout can
+// be set on its own.
+var QueryOperations = []string{
+	"create", "get_by_id", "get_by_login", "update", "delete", "restore", "hard_delete", "purge", "list",
+	"refresh_token_create", "refresh_token_get_by_hash", "refresh_token_revoke", "refresh_token_revoke_family",
+}
+
+// QueryTimeouts bounds how long a single repository operation may run.
+// PerOperation overrides Default for the named operation (e.g. "list");
+// a zero duration disables the deadline.
+type QueryTimeouts struct {
+	Default      time.Duration            `yaml:"default" toml:"default"`
+	PerOperation map[string]time.Duration `yaml:"per_operation" toml:"per_operation"`
+}
+
+func (t QueryTimeouts) For(operation string) time.Duration {
+	if d, ok := t.PerOperation[operation]; ok {
+		return d
+	}
+	return t.Default
+}
+
+type ServerConfig struct {
+	Addr              string        `yaml:"addr" toml:"addr"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" toml:"write_timeout"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" toml:"max_header_bytes"`
+	MaxBodyBytes      int64         `yaml:"max_body_bytes" toml:"max_body_bytes"`
+	// ShutdownTimeout bounds how long in-flight requests may drain
+	// after a termination signal.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+	// DrainDelay is how long the server keeps serving after a termination
+	// signal while /readyz already fails, giving load balancers time to
+	// stop routing to it.
+	DrainDelay time.Duration `yaml:"drain_delay" toml:"drain_delay"`
+	// HealthCheckTimeout bounds each dependency check of /readyz and
+	// /health.
+	HealthCheckTimeout time.Duration `yaml:"health_check_timeout" toml:"health_check_timeout"`
+}
+
+type LoggingConfig struct {
+	// Level is one of debug, info, warn or error.
+	Level string `yaml:"level" toml:"level"`
+	// Format is either json or text.
+	Format string `yaml:"format" toml:"format"`
+}
+
+type MigrationConfig struct {
+	// AutoMigrate applies pending migrations when the server starts.
+	AutoMigrate bool `yaml:"auto_migrate" toml:"auto_migrate"`
+	// Dir overrides the migrations embedded in the binary with an
+	// external directory, which is handy while developing migrations. It
+	// must hold the migrations of the configured driver, e.g.
+	// db/migrations/postgres.
+	Dir string `yaml:"dir" toml:"dir"`
+}
+
+// MinHMACSecretLength is the HS256 key size recommended by RFC 7518.
+const MinHMACSecretLength = 32
+
+type AuthConfig struct {
+	Issuer          string        `yaml:"issuer" toml:"issuer"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"`
+	// SigningAlgorithm is one of HS256, RS256 or EdDSA. HS256 signs with
+	// HMACSecret, the others with the PEM key at PrivateKeyFile.
+	SigningAlgorithm string `yaml:"signing_algorithm" toml:"signing_algorithm"`
+	KeyID            string `yaml:"key_id" toml:"key_id"`
+	HMACSecret       string `yaml:"hmac_secret" toml:"hmac_secret"`
+	PrivateKeyFile   string `yaml:"private_key_file" toml:"private_key_file"`
+	// PublicKeyFiles maps key IDs of retired signing keys to PEM public
+	// key files so tokens they signed stay valid until they expire.
+	PublicKeyFiles map[string]string `yaml:"public_key_files" toml:"public_key_files"`
+	// InitialAdmin is created on startup when no user has its username,
+	// so a fresh deployment has someone who can grant roles.
+	InitialAdmin AdminConfig `yaml:"initial_admin" toml:"initial_admin"`
+}
+
+// AdminConfig is an admin account. It is disabled while Username is
+// empty; the password only applies when the account is created.
+type AdminConfig struct {
+	Username string `yaml:"username" toml:"username"`
+	Email    string `yaml:"email" toml:"email"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+// PurgeConfig controls the background job that permanently removes users
+// soft-deleted longer than Retention ago. A zero Retention disables it.
+type PurgeConfig struct {
+	Retention time.Duration `yaml:"retention" toml:"retention"`
+	Interval  time.Duration `yaml:"interval" toml:"interval"`
+}
+
+// TracingConfig selects where OpenTelemetry spans are exported.
+type TracingConfig struct {
+	// Exporter is one of none, otlp, stdout or file.
+	Exporter string `yaml:"exporter" toml:"exporter"`
+	// OTLPEndpoint is the OTLP/HTTP collector URL, e.g.
+	// http://localhost:4318. When empty the standard OTEL_EXPORTER_OTLP_*
+	// variables apply.
+	OTLPEndpoint string `yaml:"otlp_endpoint" toml:"otlp_endpoint"`
+	// File receives the spans of the file exporter, one JSON document
+	// per span.
+	File string `yaml:"file" toml:"file"`
+	// SampleRatio is the fraction of new traces that are recorded.
+	// Requests joining a trace follow the sampling decision of the caller.
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"`
+	ServiceName string  `yaml:"service_name" toml:"service_name"`
+}
+
+type PasswordConfig struct {
+	Algorithm     string `yaml:"algorithm" toml:"algorithm"`
+	BcryptCost    int    `yaml:"bcrypt_cost" toml:"bcrypt_cost"`
+	Argon2Time    uint32 `yaml:"argon2_time" toml:"argon2_time"`
+	Argon2Memory  uint32 `yaml:"argon2_memory_kib" toml:"argon2_memory_kib"`
+	Argon2Threads uint8  `yaml:"argon2_threads" toml:"argon2_threads"`
+}
+
+// defaultPorts are the database.port defaults of each driver.
+var defaultPorts = map[string]string{
+	DriverMySQL:    "3307",
+	DriverPostgres: "5432",
+}
+
+// Default returns the configuration used for every setting that is not
+// configured otherwise. It has no HS256 secret, so it does not validate.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:               ":8080",
+			ReadTimeout:        15 * time.Second,
+			ReadHeaderTimeout:  5 * time.Second,
+			WriteTimeout:       15 * time.Second,
+			IdleTimeout:        60 * time.Second,
+			MaxHeaderBytes:     1 << 20,
+			MaxBodyBytes:       1 << 20,
+			ShutdownTimeout:    30 * time.Second,
+			HealthCheckTimeout: 2 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Driver:  DriverMySQL,
+			Host:    "127.0.0.1",
+			User:    "app_user",
+			DBName:  "db_go_crud",
+			SSLMode: "disable",
+			Path:    "go-crud.db",
+			QueryTimeouts: QueryTimeouts{
+				Default:      5 * time.Second,
+				PerOperation: map[string]time.Duration{},
+			},
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Auth: AuthConfig{
+			Issuer:           "go-crud",
+			AccessTokenTTL:   15 * time.Minute,
+			RefreshTokenTTL:  30 * 24 * time.Hour,
+			SigningAlgorithm: "HS256",
+			KeyID:            "default",
+			PublicKeyFiles:   map[string]string{},
+		},
+		Purge: PurgeConfig{
+			Retention: 30 * 24 * time.Hour,
+			Interval:  time.Hour,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			File:        "traces.json",
+			SampleRatio: 1,
+			ServiceName: "go-crud",
+		},
+		Password: PasswordConfig{
+			Algorithm:     "bcrypt",
+			BcryptCost:    12,
+			Argon2Time:    3,
+			Argon2Memory:  64 * 1024,
+			Argon2Threads: 2,
+		},
+	}
+}
+
+const redacted = "[REDACTED]"
+
+// Redacted returns a copy of c with its secrets masked, safe to print.
+func (c *Config) Redacted() *Config {
+	r := *c
+	if r.Database.Password != "" {
+		r.Database.Password = redacted
+	}
+	if r.Auth.HMACSecret != "" {
+		r.Auth.HMACSecret = redacted
+	}
+	if r.Auth.InitialAdmin.Password != "" {
+		r.Auth.InitialAdmin.Password = redacted
 	}
+	return &r
 }
 
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+// Print writes c in the YAML config file format, with its secrets masked.
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
 	}
-	return defaultValue
+	return enc.Close()
 }
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"go.yaml.in/yaml/v3"
+)
+
+// Load builds the configuration from, in increasing precedence, the
+// defaults, a config file, environment variables and the flags in args,
+// and returns the arguments left after the flags. The file is named by
+// -config or CONFIG_FILE; its extension selects YAML (.yaml, .yml) or TOML
+// (.toml).
+//
+// Malformed values in the file and the environment are not fatal; they are
+// reported by Validate along with the invalid settings, so every problem
+// shows up at once.
+func Load(args []string) (*Config, []string, error) {
+	// The file is named by a flag, so the flags are parsed once to find
+	// it and again on top of the file and the environment.
+	file := os.Getenv("CONFIG_FILE")
+	if err := newFlagSet(Default(), &file).Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	cfg := Default()
+	if file != "" {
+		if err := cfg.loadFile(file); err != nil {
+			return nil, nil, err
+		}
+	}
+	cfg.loadEnv()
+
+	flags := newFlagSet(cfg, &file)
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if cfg.Database.Port == "" {
+		cfg.Database.Port = defaultPorts[cfg.Database.Driver]
+	}
+	return cfg, flags.Args(), nil
+}
+
+// setting binds a config field to its config file key and environment
+// variable. Its flag is named after the variable, e.g. DB_QUERY_TIMEOUT is
+// set by -db-query-timeout.
+type setting struct {
+	key   string
+	env   string
+	value flag.Value
+}
+
+// settings lists every field of c that can be set from the environment
+// and flags.
+func (c *Config) settings() []setting {
+	settings := []setting{
+		{"server.addr", "SERVER_ADDR", stringValue(&c.Server.Addr)},
+		{"server.read_timeout", "SERVER_READ_TIMEOUT", durationValue(&c.Server.ReadTimeout)},
+		{"server.read_header_timeout", "SERVER_READ_HEADER_TIMEOUT", durationValue(&c.Server.ReadHeaderTimeout)},
+		{"server.write_timeout", "SERVER_WRITE_TIMEOUT", durationValue(&c.Server.WriteTimeout)},
+		{"server.idle_timeout", "SERVER_IDLE_TIMEOUT", durationValue(&c.Server.IdleTimeout)},
+		{"server.max_header_bytes", "SERVER_MAX_HEADER_BYTES", intValue(&c.Server.MaxHeaderBytes)},
+		{"server.max_body_bytes", "SERVER_MAX_BODY_BYTES", int64Value(&c.Server.MaxBodyBytes)},
+		{"server.shutdown_timeout", "SERVER_SHUTDOWN_TIMEOUT", durationValue(&c.Server.ShutdownTimeout)},
+		{"server.drain_delay", "SERVER_DRAIN_DELAY", durationValue(&c.Server.DrainDelay)},
+		{"server.health_check_timeout", "SERVER_HEALTH_CHECK_TIMEOUT", durationValue(&c.Server.HealthCheckTimeout)},
+
+		{"database.driver", "DB_DRIVER", stringValue(&c.Database.Driver)},
+		{"database.host", "DB_HOST", stringValue(&c.Database.Host)},
+		{"database.port", "DB_PORT", stringValue(&c.Database.Port)},
+		{"database.user", "DB_USER", stringValue(&c.Database.User)},
+		{"database.password", "DB_PASSWORD", stringValue(&c.Database.Password)},
+		{"database.name", "DB_NAME", stringValue(&c.Database.DBName)},
+		{"database.sslmode", "DB_SSLMODE", stringValue(&c.Database.SSLMode)},
+		{"database.path", "DB_PATH", stringValue(&c.Database.Path)},
+		{"database.query_timeouts.default", "DB_QUERY_TIMEOUT", durationValue(&c.Database.QueryTimeouts.Default)},
+
+		{"logging.level", "LOG_LEVEL", stringValue(&c.Logging.Level)},
+		{"logging.format", "LOG_FORMAT", stringValue(&c.Logging.Format)},
+
+		{"migration.auto_migrate", "MIGRATE_ON_START", newBoolValue(&c.Migration.AutoMigrate)},
+		{"migration.dir", "MIGRATIONS_DIR", stringValue(&c.Migration.Dir)},
+
+		{"auth.issuer", "AUTH_ISSUER", stringValue(&c.Auth.Issuer)},
+		{"auth.access_token_ttl", "AUTH_ACCESS_TOKEN_TTL", durationValue(&c.Auth.AccessTokenTTL)},
+		{"auth.refresh_token_ttl", "AUTH_REFRESH_TOKEN_TTL", durationValue(&c.Auth.RefreshTokenTTL)},
+		{"auth.signing_algorithm", "AUTH_JWT_ALGORITHM", stringValue(&c.Auth.SigningAlgorithm)},
+		{"auth.key_id", "AUTH_JWT_KEY_ID", stringValue(&c.Auth.KeyID)},
+		{"auth.hmac_secret", "AUTH_JWT_SECRET", stringValue(&c.Auth.HMACSecret)},
+		{"auth.private_key_file", "AUTH_JWT_PRIVATE_KEY_FILE", stringValue(&c.Auth.PrivateKeyFile)},
+		{"auth.public_key_files", "AUTH_JWT_PUBLIC_KEY_FILES", stringMapValue{&c.Auth.PublicKeyFiles}},
+		{"auth.initial_admin.username", "AUTH_INITIAL_ADMIN_USERNAME", stringValue(&c.Auth.InitialAdmin.Username)},
+		{"auth.initial_admin.email", "AUTH_INITIAL_ADMIN_EMAIL", stringValue(&c.Auth.InitialAdmin.Email)},
+		{"auth.initial_admin.password", "AUTH_INITIAL_ADMIN_PASSWORD", stringValue(&c.Auth.InitialAdmin.Password)},
+
+		{"purge.retention", "USER_PURGE_RETENTION", durationValue(&c.Purge.Retention)},
+		{"purge.interval", "USER_PURGE_INTERVAL", durationValue(&c.Purge.Interval)},
+
+		{"tracing.exporter", "TRACING_EXPORTER", stringValue(&c.Tracing.Exporter)},
+		{"tracing.otlp_endpoint", "TRACING_OTLP_ENDPOINT", stringValue(&c.Tracing.OTLPEndpoint)},
+		{"tracing.file", "TRACING_FILE", stringValue(&c.Tracing.File)},
+		{"tracing.sample_ratio", "TRACING_SAMPLE_RATIO", floatValue(&c.Tracing.SampleRatio)},
+		{"tracing.service_name", "TRACING_SERVICE_NAME", stringValue(&c.Tracing.ServiceName)},
+
+		{"password.algorithm", "PASSWORD_ALGORITHM", stringValue(&c.Password.Algorithm)},
+		{"password.bcrypt_cost", "PASSWORD_BCRYPT_COST", intValue(&c.Password.BcryptCost)},
+		{"password.argon2_time", "PASSWORD_ARGON2_TIME", uintValue(&c.Password.Argon2Time)},
+		{"password.argon2_memory_kib", "PASSWORD_ARGON2_MEMORY_KIB", uintValue(&c.Password.Argon2Memory)},
+		{"password.argon2_threads", "PASSWORD_ARGON2_THREADS", uintValue(&c.Password.Argon2Threads)},
+	}
+
+	// e.g. DB_QUERY_TIMEOUT_LIST=10s
+	for _, op := range QueryOperations {
+		settings = append(settings, setting{
+			key:   "database.query_timeouts.per_operation." + op,
+			env:   "DB_QUERY_TIMEOUT_" + strings.ToUpper(op),
+			value: durationEntryValue{&c.Database.QueryTimeouts.PerOperation, op},
+		})
+	}
+	return settings
+}
+
+func flagName(env string) string {
+	return strings.ToLower(strings.ReplaceAll(env, "_", "-"))
+}
+
+// PrintUsage writes the flags Load accepts, with their defaults.
+func PrintUsage(w io.Writer) {
+	flags := newFlagSet(Default(), new(string))
+	flags.SetOutput(w)
+	fmt.Fprintf(w, "Usage: %s [flags] [migrate ARGS | config]\n\nFlags:\n", flags.Name())
+	flags.PrintDefaults()
+}
+
+// newFlagSet returns the flags of every setting of cfg, and -config, which
+// sets file. Errors are left to the caller to report.
+func newFlagSet(cfg *Config, file *string) *flag.FlagSet {
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(file, "config", *file, "YAML or TOML config `file` ($CONFIG_FILE)")
+	for _, s := range cfg.settings() {
+		flags.Var(s.value, flagName(s.env), fmt.Sprintf("%s ($%s)", s.key, s.env))
+	}
+	return flags
+}
+
+// loadFile decodes the config file at path into c. Values that do not fit
+// their setting, and keys that match none, are recorded as problems.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err := dec.Decode(c)
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			// The decoder carries on after a mismatch, so every one
+			// of them is listed.
+			for _, e := range typeErr.Errors {
+				c.problems = append(c.problems, path+": "+e)
+			}
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		for _, key := range meta.Undecoded() {
+			c.problems = append(c.problems, fmt.Sprintf("%s: unknown key %s", path, key))
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q, expected .yaml, .yml or .toml", ext)
+	}
+	return nil
+}
+
+// loadEnv applies the environment variables of the settings of c. Empty
+// variables are ignored, like unset ones.
+func (c *Config) loadEnv() {
+	for _, s := range c.settings() {
+		raw := os.Getenv(s.env)
+		if raw == "" {
+			continue
+		}
+		if err := s.value.Set(raw); err != nil {
+			c.problems = append(c.problems, fmt.Sprintf("%s: invalid value %q: %v", s.env, raw, err))
+		}
+	}
+}
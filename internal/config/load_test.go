@@ -0,0 +1,221 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Layers(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  addr: ":9000"
+  write_timeout: 20s
+database:
+  driver: postgres
+  query_timeouts:
+    per_operation:
+      list: 10s
+logging:
+  level: debug
+`)
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("SERVER_ADDR", ":9100")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("DB_QUERY_TIMEOUT_PURGE", "1m")
+	t.Setenv("DB_USER", "")
+	t.Setenv("AUTH_INITIAL_ADMIN_USERNAME", "root")
+
+	cfg, args, err := Load([]string{"-server-addr", ":9200", "-migrate-on-start", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{name: "flag over environment", got: cfg.Server.Addr, want: ":9200"},
+		{name: "boolean flag", got: cfg.Migration.AutoMigrate, want: true},
+		{name: "environment over file", got: cfg.Logging.Level, want: "warn"},
+		{name: "file over default", got: cfg.Server.WriteTimeout, want: 20 * time.Second},
+		{name: "default", got: cfg.Server.ReadTimeout, want: 15 * time.Second},
+		{name: "empty variable ignored", got: cfg.Database.User, want: "app_user"},
+		{name: "nested setting", got: cfg.Auth.InitialAdmin.Username, want: "root"},
+		{name: "port follows driver", got: cfg.Database.Port, want: "5432"},
+		{name: "per operation timeouts merged", got: cfg.Database.QueryTimeouts.PerOperation, want: map[string]time.Duration{"list": 10 * time.Second, "purge": time.Minute}},
+		{name: "remaining arguments", got: args, want: []string{"migrate", "up"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !reflect.DeepEqual(test.got, test.want) {
+				t.Errorf("expected: %v, got: %v", test.want, test.got)
+			}
+		})
+	}
+}
+
+func TestLoad_FileFormats(t *testing.T) {
+	files := []struct {
+		name    string
+		content string
+	}{
+		{name: "config.yaml", content: `
+server:
+  idle_timeout: 2m
+database:
+  driver: sqlite
+  path: /var/lib/go-crud.db
+auth:
+  public_key_files:
+    old: /etc/go-crud/old.pem
+password:
+  argon2_threads: 4
+tracing:
+  sample_ratio: 0.25
+`},
+		{name: "config.toml", content: `
+[server]
+idle_timeout = "2m"
+
+[database]
+driver = "sqlite"
+path = "/var/lib/go-crud.db"
+
+[auth.public_key_files]
+old = "/etc/go-crud/old.pem"
+
+[password]
+argon2_threads = 4
+
+[tracing]
+sample_ratio = 0.25
+`},
+	}
+
+	want := Default()
+	want.Server.IdleTimeout = 2 * time.Minute
+	want.Database.Driver = DriverSQLite
+	want.Database.Path = "/var/lib/go-crud.db"
+	want.Auth.PublicKeyFiles = map[string]string{"old": "/etc/go-crud/old.pem"}
+	want.Password.Argon2Threads = 4
+	want.Tracing.SampleRatio = 0.25
+
+	for _, file := range files {
+		t.Run(file.name, func(t *testing.T) {
+			cfg, _, err := Load([]string{"-config", writeConfigFile(t, file.name, file.content)})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(cfg, want) {
+				t.Errorf("expected: %+v, got: %+v", want, cfg)
+			}
+		})
+	}
+}
+
+func TestLoad_Problems(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  adress: ":9000"
+  max_body_bytes: lots
+`)
+	t.Setenv("SERVER_READ_TIMEOUT", "15")
+	t.Setenv("PASSWORD_ARGON2_THREADS", "256")
+	t.Setenv("AUTH_JWT_PUBLIC_KEY_FILES", "old")
+	t.Setenv("AUTH_JWT_SECRET", strings.Repeat("s", MinHMACSecretLength))
+
+	cfg, _, err := Load([]string{"-config", path})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = cfg.Validate()
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected a *ValidationError, got: %v", err)
+	}
+
+	want := []string{
+		"field adress not found",
+		"line 4: cannot unmarshal !!str `lots`",
+		`SERVER_READ_TIMEOUT: invalid value "15": must be a duration`,
+		`AUTH_JWT_PUBLIC_KEY_FILES: invalid value "old"`,
+		`PASSWORD_ARGON2_THREADS: invalid value "256": out of range`,
+	}
+	if len(validationErr.Problems) != len(want) {
+		t.Fatalf("expected %d problems, got: %q", len(want), validationErr.Problems)
+	}
+	for i, problem := range validationErr.Problems {
+		if !strings.Contains(problem, want[i]) {
+			t.Errorf("expected problem %d to contain %q, got: %q", i, want[i], problem)
+		}
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "unknown flag", args: []string{"-no-such-setting", "1"}},
+		{name: "malformed flag", args: []string{"-server-read-timeout", "soon"}},
+		{name: "missing file", args: []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}},
+		{name: "unsupported extension", args: []string{"-config", writeConfigFile(t, "config.json", "{}")}},
+		{name: "malformed file", args: []string{"-config", writeConfigFile(t, "config.toml", "[server")}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := Load(test.args); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestConfig_Print(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "db-password"
+	cfg.Auth.HMACSecret = strings.Repeat("s", MinHMACSecretLength)
+	cfg.Auth.InitialAdmin = AdminConfig{Username: "root", Email: "root@email.com", Password: "admin-password"}
+
+	var out strings.Builder
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	printed := out.String()
+	for _, secret := range []string{cfg.Database.Password, cfg.Auth.HMACSecret, cfg.Auth.InitialAdmin.Password} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("expected %q to be redacted, got:\n%s", secret, printed)
+		}
+	}
+	if cfg.Database.Password != "db-password" {
+		t.Errorf("expected the config itself to keep its secrets")
+	}
+
+	// The output is a valid config file.
+	loaded, _, err := Load([]string{"-config", writeConfigFile(t, "printed.yaml", printed)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loaded.Server != cfg.Server || loaded.Auth.HMACSecret != redacted {
+		t.Errorf("expected the printed config to load back, got: %+v", loaded)
+	}
+}
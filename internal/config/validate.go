@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ValidationError lists every invalid setting, so they can all be fixed
+// at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+func (e *ValidationError) err() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// envNames maps config file keys to their environment variables.
+var envNames = func() map[string]string {
+	names := map[string]string{}
+	for _, s := range (&Config{}).settings() {
+		names[s.key] = s.env
+	}
+	return names
+}()
+
+// check adds a problem with the setting at key unless ok.
+func (e *ValidationError) check(ok bool, key, format string, args ...any) {
+	if ok {
+		return
+	}
+	if env, found := envNames[key]; found {
+		key += " (" + env + ")"
+	}
+	e.Problems = append(e.Problems, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (e *ValidationError) checkDuration(d time.Duration, key string) {
+	e.check(d >= 0, key, "must not be negative")
+}
+
+// Validate checks every setting and reports all problems, including the
+// malformed values Load skipped, as a *ValidationError.
+func (c *Config) Validate() error {
+	problems := &ValidationError{Problems: slices.Clone(c.problems)}
+	c.Server.validate(problems)
+	c.Database.validate(problems)
+	c.Logging.validate(problems)
+	c.Auth.validate(problems)
+	c.Purge.validate(problems)
+	c.Tracing.validate(problems)
+	c.Password.validate(problems)
+	return problems.err()
+}
+
+// ValidateDatabase is Validate for commands that need nothing but the
+// database settings.
+func (c *Config) ValidateDatabase() error {
+	problems := &ValidationError{Problems: slices.Clone(c.problems)}
+	c.Database.validate(problems)
+	return problems.err()
+}
+
+func (c ServerConfig) validate(problems *ValidationError) {
+	_, _, err := net.SplitHostPort(c.Addr)
+	problems.check(err == nil, "server.addr", "must be host:port or :port, got %q", c.Addr)
+	problems.checkDuration(c.ReadTimeout, "server.read_timeout")
+	problems.checkDuration(c.ReadHeaderTimeout, "server.read_header_timeout")
+	problems.checkDuration(c.WriteTimeout, "server.write_timeout")
+	problems.checkDuration(c.IdleTimeout, "server.idle_timeout")
+	problems.check(c.MaxHeaderBytes > 0, "server.max_header_bytes", "must be positive")
+	problems.check(c.MaxBodyBytes > 0, "server.max_body_bytes", "must be positive")
+	problems.checkDuration(c.ShutdownTimeout, "server.shutdown_timeout")
+	problems.checkDuration(c.DrainDelay, "server.drain_delay")
+	problems.checkDuration(c.HealthCheckTimeout, "server.health_check_timeout")
+}
+
+var postgresSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+func (c DatabaseConfig) validate(problems *ValidationError) {
+	switch c.Driver {
+	case DriverMySQL, DriverPostgres:
+		problems.check(c.Host != "", "database.host", "is required for %s", c.Driver)
+		port, err := strconv.Atoi(c.Port)
+		problems.check(err == nil && port > 0 && port <= 65535, "database.port", "must be a port number, got %q", c.Port)
+		problems.check(c.User != "", "database.user", "is required for %s", c.Driver)
+		problems.check(c.DBName != "", "database.name", "is required for %s", c.Driver)
+		if c.Driver == DriverPostgres {
+			problems.check(slices.Contains(postgresSSLModes, c.SSLMode), "database.sslmode", "must be one of %s, got %q", strings.Join(postgresSSLModes, ", "), c.SSLMode)
+		}
+	case DriverSQLite:
+		problems.check(c.Path != "", "database.path", "is required for %s", c.Driver)
+	case DriverMemory:
+	default:
+		problems.check(false, "database.driver", "must be one of %s, %s, %s or %s, got %q", DriverMySQL, DriverPostgres, DriverSQLite, DriverMemory, c.Driver)
+	}
+
+	problems.checkDuration(c.QueryTimeouts.Default, "database.query_timeouts.default")
+	for op, d := range c.QueryTimeouts.PerOperation {
+		key := "database.query_timeouts.per_operation." + op
+		problems.check(slices.Contains(QueryOperations, op), key, "unknown operation, expected one of %s", strings.Join(QueryOperations, ", "))
+		problems.checkDuration(d, key)
+	}
+}
+
+func (c LoggingConfig) validate(problems *ValidationError) {
+	var level slog.Level
+	problems.check(level.UnmarshalText([]byte(c.Level)) == nil, "logging.level", "must be one of debug, info, warn or error, got %q", c.Level)
+	format := strings.ToLower(c.Format)
+	problems.check(format == "json" || format == "text", "logging.format", "must be json or text, got %q", c.Format)
+}
+
+func (c AuthConfig) validate(problems *ValidationError) {
+	problems.check(c.Issuer != "", "auth.issuer", "is required")
+	problems.check(c.AccessTokenTTL > 0, "auth.access_token_ttl", "must be positive")
+	problems.check(c.RefreshTokenTTL > 0, "auth.refresh_token_ttl", "must be positive")
+	problems.check(c.KeyID != "", "auth.key_id", "is required")
+
+	switch c.SigningAlgorithm {
+	case "HS256":
+		problems.check(len(c.HMACSecret) >= MinHMACSecretLength, "auth.hmac_secret", "must be at least %d bytes for HS256", MinHMACSecretLength)
+	case "RS256", "EdDSA":
+		problems.check(c.PrivateKeyFile != "", "auth.private_key_file", "is required for %s", c.SigningAlgorithm)
+	default:
+		problems.check(false, "auth.signing_algorithm", "must be one of HS256, RS256 or EdDSA, got %q", c.SigningAlgorithm)
+	}
+
+	if admin := c.InitialAdmin; admin != (AdminConfig{}) {
+		problems.check(admin.Username != "", "auth.initial_admin.username", "is required for the initial admin")
+		problems.check(admin.Email != "", "auth.initial_admin.email", "is required for the initial admin")
+		problems.check(admin.Password != "", "auth.initial_admin.password", "is required for the initial admin")
+	}
+}
+
+func (c PurgeConfig) validate(problems *ValidationError) {
+	problems.checkDuration(c.Retention, "purge.retention")
+	if c.Retention > 0 {
+		problems.check(c.Interval > 0, "purge.interval", "must be positive while purging is enabled")
+	}
+}
+
+func (c TracingConfig) validate(problems *ValidationError) {
+	switch c.Exporter {
+	case "none", "otlp", "stdout":
+	case "file":
+		problems.check(c.File != "", "tracing.file", "is required for the file exporter")
+	default:
+		problems.check(false, "tracing.exporter", "must be one of none, otlp, stdout or file, got %q", c.Exporter)
+	}
+	problems.check(c.SampleRatio >= 0 && c.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1, got %v", c.SampleRatio)
+	problems.check(c.ServiceName != "", "tracing.service_name", "is required")
+}
+
+func (c PasswordConfig) validate(problems *ValidationError) {
+	switch c.Algorithm {
+	case "bcrypt", "argon2id":
+	default:
+		problems.check(false, "password.algorithm", "must be bcrypt or argon2id, got %q", c.Algorithm)
+	}
+	// Both hashers are always built, to verify hashes made by either.
+	problems.check(c.BcryptCost >= bcrypt.MinCost && c.BcryptCost <= bcrypt.MaxCost, "password.bcrypt_cost", "must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	problems.check(c.Argon2Time > 0, "password.argon2_time", "must be positive")
+	problems.check(c.Argon2Threads > 0, "password.argon2_threads", "must be positive")
+	// Argon2 needs at least 8 KiB per thread.
+	problems.check(c.Argon2Memory >= 8*uint32(c.Argon2Threads), "password.argon2_memory_kib", "must be at least 8 per thread")
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func validConfig() *Config {
+	cfg := Default()
+	cfg.Database.Port = "3307"
+	cfg.Auth.HMACSecret = strings.Repeat("s", MinHMACSecretLength)
+	return cfg
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   []string
+	}{
+		{
+			name:   "valid",
+			modify: func(cfg *Config) {},
+		},
+		{
+			name: "memory driver needs no connection settings",
+			modify: func(cfg *Config) {
+				cfg.Database = DatabaseConfig{Driver: DriverMemory}
+			},
+		},
+		{
+			name: "RS256 with a key file",
+			modify: func(cfg *Config) {
+				cfg.Auth.SigningAlgorithm, cfg.Auth.HMACSecret, cfg.Auth.PrivateKeyFile = "RS256", "", "key.pem"
+			},
+		},
+		{
+			name: "every problem is reported",
+			modify: func(cfg *Config) {
+				cfg.Server.Addr = "8080"
+				cfg.Server.ShutdownTimeout = -time.Second
+				cfg.Database.Port = "port"
+				cfg.Logging.Format = "xml"
+				cfg.Auth.HMACSecret = "short"
+			},
+			want: []string{
+				"server.addr (SERVER_ADDR): must be host:port or :port",
+				"server.shutdown_timeout (SERVER_SHUTDOWN_TIMEOUT): must not be negative",
+				`database.port (DB_PORT): must be a port number, got "port"`,
+				`logging.format (LOG_FORMAT): must be json or text, got "xml"`,
+				"auth.hmac_secret (AUTH_JWT_SECRET): must be at least 32 bytes for HS256",
+			},
+		},
+		{
+			name: "unknown driver",
+			modify: func(cfg *Config) {
+				cfg.Database.Driver = "oracle"
+			},
+			want: []string{`database.driver (DB_DRIVER): must be one of mysql, postgres, sqlite or memory, got "oracle"`},
+		},
+		{
+			name: "postgres sslmode",
+			modify: func(cfg *Config) {
+				cfg.Database.Driver, cfg.Database.SSLMode = DriverPostgres, "on"
+			},
+			want: []string{"database.sslmode (DB_SSLMODE): must be one of disable"},
+		},
+		{
+			name: "unknown query operation",
+			modify: func(cfg *Config) {
+				cfg.Database.QueryTimeouts.PerOperation["lsit"] = time.Second
+			},
+			want: []string{"database.query_timeouts.per_operation.lsit: unknown operation"},
+		},
+		{
+			name: "key file required",
+			modify: func(cfg *Config) {
+				cfg.Auth.SigningAlgorithm = "EdDSA"
+			},
+			want: []string{"auth.private_key_file (AUTH_JWT_PRIVATE_KEY_FILE): is required for EdDSA"},
+		},
+		{
+			name: "initial admin",
+			modify: func(cfg *Config) {
+				cfg.Auth.InitialAdmin = AdminConfig{Username: "root"}
+			},
+			want: []string{
+				"auth.initial_admin.email (AUTH_INITIAL_ADMIN_EMAIL): is required for the initial admin",
+				"auth.initial_admin.password (AUTH_INITIAL_ADMIN_PASSWORD): is required for the initial admin",
+			},
+		},
+		{
+			name: "purge interval",
+			modify: func(cfg *Config) {
+				cfg.Purge.Interval = 0
+			},
+			want: []string{"purge.interval (USER_PURGE_INTERVAL): must be positive while purging is enabled"},
+		},
+		{
+			name: "tracing",
+			modify: func(cfg *Config) {
+				cfg.Tracing.Exporter, cfg.Tracing.SampleRatio = "zipkin", 2
+			},
+			want: []string{
+				`tracing.exporter (TRACING_EXPORTER): must be one of none, otlp, stdout or file, got "zipkin"`,
+				"tracing.sample_ratio (TRACING_SAMPLE_RATIO): must be between 0 and 1, got 2",
+			},
+		},
+		{
+			name: "password hashing",
+			modify: func(cfg *Config) {
+				cfg.Password.BcryptCost = 40
+				cfg.Password.Argon2Memory = 8
+			},
+			want: []string{
+				"password.bcrypt_cost (PASSWORD_BCRYPT_COST): must be between 4 and 31",
+				"password.argon2_memory_kib (PASSWORD_ARGON2_MEMORY_KIB): must be at least 8 per thread",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := validConfig()
+			test.modify(cfg)
+
+			err := cfg.Validate()
+			if len(test.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			validationErr, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("expected a *ValidationError, got: %v", err)
+			}
+			if len(validationErr.Problems) != len(test.want) {
+				t.Fatalf("expected %d problems, got: %q", len(test.want), validationErr.Problems)
+			}
+			for i, problem := range validationErr.Problems {
+				if !strings.HasPrefix(problem, test.want[i]) {
+					t.Errorf("expected problem %d to start with %q, got: %q", i, test.want[i], problem)
+				}
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateDatabase(t *testing.T) {
+	cfg := validConfig()
+	cfg.Auth.HMACSecret = ""
+	if err := cfg.ValidateDatabase(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	cfg.Database.Driver = "oracle"
+	if err := cfg.ValidateDatabase(); err == nil {
+		t.Errorf("expected an error for an unsupported driver")
+	}
+}
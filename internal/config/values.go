@@ -0,0 +1,172 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// value adapts a config field to flag.Value, so environment variables and
+// flags are parsed the same way.
+type value[T any] struct {
+	p     *T
+	parse func(string) (T, error)
+}
+
+func (v value[T]) Set(s string) error {
+	parsed, err := v.parse(s)
+	if err != nil {
+		return err
+	}
+	*v.p = parsed
+	return nil
+}
+
+func (v value[T]) String() string {
+	// The flag package calls String on a zero value to detect defaults.
+	if v.p == nil {
+		return ""
+	}
+	return fmt.Sprint(*v.p)
+}
+
+type boolValue struct {
+	value[bool]
+}
+
+// IsBoolFlag allows -flag as a shorthand for -flag=true.
+func (boolValue) IsBoolFlag() bool {
+	return true
+}
+
+func stringValue(p *string) value[string] {
+	return value[string]{p, func(s string) (string, error) { return s, nil }}
+}
+
+func intValue(p *int) value[int] {
+	return value[int]{p, func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		return n, parseError(err, "must be an integer")
+	}}
+}
+
+func int64Value(p *int64) value[int64] {
+	return value[int64]{p, func(s string) (int64, error) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		return n, parseError(err, "must be an integer")
+	}}
+}
+
+func uintValue[T uint8 | uint32](p *T) value[T] {
+	bits := reflect.TypeFor[T]().Bits()
+	return value[T]{p, func(s string) (T, error) {
+		n, err := strconv.ParseUint(s, 10, bits)
+		return T(n), parseError(err, "must be a non-negative integer")
+	}}
+}
+
+func floatValue(p *float64) value[float64] {
+	return value[float64]{p, func(s string) (float64, error) {
+		f, err := strconv.ParseFloat(s, 64)
+		return f, parseError(err, "must be a number")
+	}}
+}
+
+func newBoolValue(p *bool) boolValue {
+	return boolValue{value[bool]{p, func(s string) (bool, error) {
+		b, err := strconv.ParseBool(s)
+		return b, parseError(err, "must be true or false")
+	}}}
+}
+
+func durationValue(p *time.Duration) value[time.Duration] {
+	return value[time.Duration]{p, parseDuration}
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, errors.New("must be a duration such as 30s or 5m")
+	}
+	return d, nil
+}
+
+// parseError replaces strconv's errors, which repeat the input, with
+// message. Values out of range keep saying so.
+func parseError(err error, message string) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		return errors.New("out of range")
+	}
+	return errors.New(message)
+}
+
+// stringMapValue parses a comma separated list of key=value pairs, which
+// replaces the whole map.
+type stringMapValue struct {
+	p *map[string]string
+}
+
+func (v stringMapValue) Set(s string) error {
+	result := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, val, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("%q is not a key=value pair", pair)
+		}
+		result[k] = val
+	}
+	*v.p = result
+	return nil
+}
+
+func (v stringMapValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	pairs := []string{}
+	for _, k := range slices.Sorted(maps.Keys(*v.p)) {
+		pairs = append(pairs, k+"="+(*v.p)[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// durationEntryValue sets a single key of a duration map, leaving the
+// other keys alone.
+type durationEntryValue struct {
+	p   *map[string]time.Duration
+	key string
+}
+
+func (v durationEntryValue) Set(s string) error {
+	d, err := parseDuration(s)
+	if err != nil {
+		return err
+	}
+	if *v.p == nil {
+		*v.p = map[string]time.Duration{}
+	}
+	(*v.p)[v.key] = d
+	return nil
+}
+
+func (v durationEntryValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	if d, ok := (*v.p)[v.key]; ok {
+		return d.String()
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// RefreshToken is a server-side record of an issued refresh token. Only
+// the SHA-256 hash of the token is stored. Tokens rotated from the same
+// login share a FamilyID so the whole chain can be revoked at once.
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	FamilyID  string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*RefreshToken, error)
+	// Revoke returns ErrNotFound when the token does not exist or has
+	// already been revoked.
+	Revoke(ctx context.Context, id int64) error
+	RevokeFamily(ctx context.Context, familyID string) error
+}
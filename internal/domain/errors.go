@@ -5,4 +5,41 @@ import "errors"
 var (
 	ErrNotFound      = errors.New("resource not found")
 	ErrAlreadyExists = errors.New("resource already exists")
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+	ErrTimeout       = errors.New("operation timed out")
+	ErrCanceled      = errors.New("operation canceled")
+	ErrConflict      = errors.New("resource was modified concurrently")
+
+	// Constraint violations reported by the storage layer.
+	ErrValueTooLong     = errors.New("value too long")
+	ErrRequiredValue    = errors.New("required value missing")
+	ErrReferenced       = errors.New("resource is still referenced")
+	ErrInvalidReference = errors.New("referenced resource does not exist")
+
+	// Transient storage failures; retrying the operation later may
+	// succeed.
+	ErrDeadlock    = errors.New("transaction deadlocked")
+	ErrLockTimeout = errors.New("lock wait timed out")
+	ErrUnavailable = errors.New("storage unavailable")
+	ErrReadOnly    = errors.New("storage is read-only")
+
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrForbidden          = errors.New("forbidden")
 )
+
+// ConstraintError names the field a constraint violation is about. Err is
+// one of the constraint errors above, e.g. ErrAlreadyExists for a taken
+// email address.
+type ConstraintError struct {
+	Field string
+	Err   error
+}
+
+func (e *ConstraintError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return e.Err
+}
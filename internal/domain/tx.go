@@ -0,0 +1,19 @@
+package domain
+
+import "context"
+
+// Repositories groups the repositories that can take part in a unit of
+// work.
+type Repositories struct {
+	Users         UserRepository
+	RefreshTokens RefreshTokenRepository
+}
+
+// TxManager runs units of work. Everything fn does through repos is
+// committed when fn returns nil and rolled back when it returns an error
+// or panics. Calling WithinTx with a ctx that belongs to a running unit
+// of work nests the new one inside it, so only the nested part is rolled
+// back when it fails.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context, repos Repositories) error) error
+}
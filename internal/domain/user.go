@@ -1,25 +1,63 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleUser || r == RoleAdmin
+}
+
+// User is a registered account. Version is incremented by every update
+// and backs optimistic concurrency control. DeletedAt is set while the user
+// is soft-deleted.
 type User struct {
-	ID        int64     `json:"id"         db:"id"`
-	Email     string    `json:"email"      db:"email"`
-	Username  string    `json:"username"   db:"username"`
-	Password  string    `json:"-"          db:"password"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID        int64      `json:"id"                   db:"id"`
+	Email     string     `json:"email"                db:"email"`
+	Username  string     `json:"username"             db:"username"`
+	Password  string     `json:"-"                    db:"password"`
+	Role      Role       `json:"role"                 db:"role"`
+	Version   int64      `json:"version"              db:"version"`
+	CreatedAt time.Time  `json:"created_at"           db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"           db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
 
 type UserRepository interface {
-	Create(user *User) error
-	GetByID(id int64) (*User, error)
-	Update(id int64, upd *UserUpdate) error
-	Delete(id int64) error
+	Create(ctx context.Context, user *User) error
+	GetByID(ctx context.Context, id int64) (*User, error)
+	// GetByLogin finds a user by username or email, including the
+	// password hash.
+	GetByLogin(ctx context.Context, login string) (*User, error)
+	// Update applies upd. When upd.Version is set the update only
+	// happens if the stored version still matches, ErrConflict otherwise.
+	Update(ctx context.Context, id int64, upd *UserUpdate) error
+	// Delete soft-deletes a user. Soft-deleted users are invisible to
+	// every other method except Restore, HardDelete and Purge.
+	Delete(ctx context.Context, id int64) error
+	// Restore undoes Delete.
+	Restore(ctx context.Context, id int64) error
+	// HardDelete permanently removes a user, deleted or not.
+	HardDelete(ctx context.Context, id int64) error
+	// Purge permanently removes users soft-deleted before the given time
+	// and returns how many were removed.
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
+	List(ctx context.Context, params UserListParams) (*UserList, error)
 }
 
 type UserUpdate struct {
 	Username *string `json:"username,omitempty"`
 	Email    *string `json:"email,omitempty"`
 	Password *string `json:"password,omitempty"`
+	Role     *Role   `json:"role,omitempty"`
+	// Version is the version the caller expects to update, if any.
+	Version *int64 `json:"-"`
 }
@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+type UserSortField string
+
+const (
+	SortByID        UserSortField = "id"
+	SortByUsername  UserSortField = "username"
+	SortByEmail     UserSortField = "email"
+	SortByCreatedAt UserSortField = "created_at"
+	SortByUpdatedAt UserSortField = "updated_at"
+)
+
+// UserSortFields is the whitelist of columns users can be sorted by.
+var UserSortFields = map[UserSortField]bool{
+	SortByID:        true,
+	SortByUsername:  true,
+	SortByEmail:     true,
+	SortByCreatedAt: true,
+	SortByUpdatedAt: true,
+}
+
+type UserFilter struct {
+	EmailPrefix    string
+	UsernamePrefix string
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	// Deleted lists soft-deleted users instead of active ones.
+	Deleted bool
+}
+
+type UserListParams struct {
+	Filter       UserFilter
+	SortBy       UserSortField
+	SortDesc     bool
+	Limit        int
+	Offset       int
+	Cursor       *UserCursor
+	IncludeTotal bool
+}
+
+type UserList struct {
+	Users      []User
+	NextCursor *UserCursor
+	Total      *int64
+}
+
+// UserCursor marks the last row of a page for keyset pagination. Value
+// holds the sort column of that row so pages stay stable when sorting by
+// columns other than id.
+type UserCursor struct {
+	SortBy   UserSortField `json:"s"`
+	SortDesc bool          `json:"d,omitempty"`
+	Value    string        `json:"v,omitempty"`
+	ID       int64         `json:"id"`
+}
+
+func (c UserCursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func DecodeUserCursor(s string) (*UserCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c UserCursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if !UserSortFields[c.SortBy] {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
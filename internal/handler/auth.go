@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"go-crud/internal/auth"
+	"go-crud/internal/logging"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type AuthHandler struct {
+	auth *auth.Service
+}
+
+func NewAuthHandler(authService *auth.Service) *AuthHandler {
+	return &AuthHandler{
+		auth: authService,
+	}
+}
+
+type loginRequest struct {
+	// Login is either the username or the email of the user.
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+}
+
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if req.Login == "" || req.Password == "" {
+		WriteError(w, r, ErrInvalidJSON)
+		return
+	}
+
+	tokens, err := h.auth.Login(r.Context(), req.Login, req.Password)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	writeTokens(w, tokens)
+}
+
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if req.RefreshToken == "" {
+		WriteError(w, r, ErrInvalidJSON)
+		return
+	}
+
+	tokens, err := h.auth.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	writeTokens(w, tokens)
+}
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if req.RefreshToken == "" {
+		WriteError(w, r, ErrInvalidJSON)
+		return
+	}
+
+	if err := h.auth.Logout(r.Context(), req.RefreshToken); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeTokens(w http.ResponseWriter, tokens *auth.TokenPair) {
+	w.Header().Set("Cache-Control", "no-store")
+	WriteResponse(w, tokenResponse{
+		AccessToken:      tokens.AccessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(time.Until(tokens.AccessTokenExpiresAt).Seconds()),
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresIn: int64(time.Until(tokens.RefreshTokenExpiresAt).Seconds()),
+	}, http.StatusOK)
+}
+
+// Authenticate rejects requests without a valid bearer access token and
+// stores the authenticated principal in the request context.
+func Authenticate(tokens *auth.TokenManager) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer`)
+				WriteError(w, r, ErrMissingToken)
+				return
+			}
+
+			principal, err := tokens.Parse(token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				WriteError(w, r, err)
+				return
+			}
+
+			ctx := auth.WithPrincipal(r.Context(), principal)
+			ctx = logging.WithAttrs(ctx, slog.Int64("user_id", principal.UserID))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"go-crud/internal/auth"
+	"go-crud/internal/domain"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestTokens() *auth.TokenManager {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	return auth.NewTokenManager(auth.Key{ID: "test", Method: jwt.SigningMethodHS256, Sign: secret, Verify: secret}, "test", time.Minute)
+}
+
+func TestAuthenticate(t *testing.T) {
+	tokens := newTestTokens()
+	valid, _, err := tokens.Issue(auth.Principal{UserID: 7, Username: "testuser", Role: domain.RoleUser})
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+		wantBody      string
+	}{
+		{
+			name:          "valid token",
+			authorization: "Bearer " + valid,
+			wantStatus:    http.StatusOK,
+			wantBody:      "7",
+		},
+		{
+			name:          "missing header",
+			authorization: "",
+			wantStatus:    http.StatusUnauthorized,
+			wantBody:      `{"error":"missing bearer token"}`,
+		},
+		{
+			name:          "wrong scheme",
+			authorization: "Basic dXNlcjpwYXNz",
+			wantStatus:    http.StatusUnauthorized,
+			wantBody:      `{"error":"missing bearer token"}`,
+		},
+		{
+			name:          "invalid token",
+			authorization: "Bearer invalid",
+			wantStatus:    http.StatusUnauthorized,
+			wantBody:      `{"error":"unauthorized: invalid or expired token"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal, ok := auth.PrincipalFromContext(r.Context())
+				if !ok {
+					t.Fatal("expected principal in context")
+				}
+				io.WriteString(w, "7")
+				if principal.UserID != 7 {
+					t.Errorf("expected user id 7, got: %d", principal.UserID)
+				}
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+			req.Header.Set("Accept", "application/json")
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+			Authenticate(tokens)(next).ServeHTTP(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+
+			if test.wantStatus != resp.StatusCode {
+				t.Errorf("expected status code: %v, got: %v", test.wantStatus, resp.StatusCode)
+			}
+			if test.wantStatus == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("expected WWW-Authenticate header")
+			}
+			respBody, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read response body: %v", err)
+			}
+			if strings.TrimSpace(string(respBody)) != test.wantBody {
+				t.Errorf("expected response body: %s, got: %s", test.wantBody, respBody)
+			}
+		})
+	}
+}
+
+func TestAuthHandler_LoginInvalidBody(t *testing.T) {
+	handler := NewAuthHandler(nil)
+
+	for _, body := range []string{`{"login":"testuser"`, `{"login":"testuser"}`, `{"password":"password123"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		handler.Login(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %s: expected status code: %v, got: %v", body, http.StatusBadRequest, w.Code)
+		}
+	}
+}
@@ -2,11 +2,19 @@ package handler
 
 import (
 	"errors"
+	"fmt"
 	"go-crud/internal/domain"
+	"go-crud/internal/logging"
+	"go-crud/internal/patch"
+	"go-crud/internal/validation"
 	"net/http"
 )
 
+// HTTPError is an error with a fixed response. Type and Title identify the
+// problem type; Message becomes the problem detail.
 type HTTPError struct {
+	Type    string
+	Title   string
 	Message string
 	Code    int
 }
@@ -16,18 +24,175 @@ func (e *HTTPError) Error() string {
 }
 
 var (
-	ErrInvalidJSON = &HTTPError{Message: "invalid json", Code: http.StatusBadRequest}
-	ErrInvalidID   = &HTTPError{Message: "invalid parameter 'id'", Code: http.StatusBadRequest}
-	ErrInvalidPath = &HTTPError{Message: "invalid path format", Code: http.StatusBadRequest}
+	ErrInvalidJSON = &HTTPError{Type: "invalid-json", Title: "Malformed request body", Message: "invalid json", Code: http.StatusBadRequest}
+	ErrInvalidID   = &HTTPError{Type: "invalid-id", Title: "Invalid resource id", Message: "invalid parameter 'id'", Code: http.StatusBadRequest}
+	ErrInvalidPath = &HTTPError{Type: "invalid-path", Title: "Invalid path", Message: "invalid path format", Code: http.StatusBadRequest}
+
+	ErrPasswordTooLong = &HTTPError{Type: "password-too-long", Title: "Password too long", Message: "password is too long", Code: http.StatusBadRequest}
+	ErrMissingToken    = &HTTPError{Type: "unauthorized", Title: "Authentication required", Message: "missing bearer token", Code: http.StatusUnauthorized}
+	ErrBodyTooLarge    = &HTTPError{Type: "body-too-large", Title: "Request body too large", Message: "request body too large", Code: http.StatusRequestEntityTooLarge}
+
+	ErrUnsupportedMediaType = &HTTPError{Type: "unsupported-media-type", Title: "Unsupported media type", Message: "unsupported content type", Code: http.StatusUnsupportedMediaType}
+	ErrPreconditionFailed   = &HTTPError{Type: "precondition-failed", Title: "Precondition failed", Message: "resource has been modified", Code: http.StatusPreconditionFailed}
+	ErrMethodNotAllowed     = &HTTPError{Type: "method-not-allowed", Title: "Method not allowed", Message: "method not allowed", Code: http.StatusMethodNotAllowed}
+)
+
+// statusClientClosedRequest is the non-standard status, borrowed from nginx,
+// for requests the client gave up on. The client never reads the response;
+// the status keeps them apart from server errors in logs and metrics.
+const statusClientClosedRequest = 499
+
+// Responses for domain errors, see toHTTPError.
+var (
+	errValidation         = &HTTPError{Type: "validation-failed", Title: "Validation failed", Message: "validation failed", Code: http.StatusUnprocessableEntity}
+	errNotFound           = &HTTPError{Type: "not-found", Title: "Resource not found", Message: domain.ErrNotFound.Error(), Code: http.StatusNotFound}
+	errAlreadyExists      = &HTTPError{Type: "already-exists", Title: "Resource already exists", Message: domain.ErrAlreadyExists.Error(), Code: http.StatusConflict}
+	errConflict           = &HTTPError{Type: "conflict", Title: "Concurrent modification", Message: domain.ErrConflict.Error(), Code: http.StatusConflict}
+	errInvalidCursor      = &HTTPError{Type: "invalid-cursor", Title: "Invalid pagination cursor", Message: domain.ErrInvalidCursor.Error(), Code: http.StatusBadRequest}
+	errInvalidCredentials = &HTTPError{Type: "invalid-credentials", Title: "Invalid credentials", Message: domain.ErrInvalidCredentials.Error(), Code: http.StatusUnauthorized}
+	errForbidden          = &HTTPError{Type: "forbidden", Title: "Forbidden", Message: domain.ErrForbidden.Error(), Code: http.StatusForbidden}
+	errTimeout            = &HTTPError{Type: "timeout", Title: "Request timed out", Message: domain.ErrTimeout.Error(), Code: http.StatusGatewayTimeout}
+	errCanceled           = &HTTPError{Type: "canceled", Title: "Request canceled", Message: domain.ErrCanceled.Error(), Code: statusClientClosedRequest}
+	errInvalidPatch       = &HTTPError{Type: "invalid-patch", Title: "Malformed patch document", Message: patch.ErrInvalidPatch.Error(), Code: http.StatusBadRequest}
+	errCannotApplyPatch   = &HTTPError{Type: "patch-not-applicable", Title: "Patch cannot be applied", Message: patch.ErrCannotApply.Error(), Code: http.StatusUnprocessableEntity}
+	errPatchTestFailed    = &HTTPError{Type: "patch-test-failed", Title: "Patch test failed", Message: patch.ErrTestFailed.Error(), Code: http.StatusConflict}
+	errReferenced         = &HTTPError{Type: "referenced", Title: "Resource is referenced", Message: domain.ErrReferenced.Error(), Code: http.StatusConflict}
+	errInvalidReference   = &HTTPError{Type: "invalid-reference", Title: "Invalid reference", Message: domain.ErrInvalidReference.Error(), Code: http.StatusUnprocessableEntity}
+	errBusy               = &HTTPError{Type: "busy", Title: "Resource busy", Message: "resource is busy, try again later", Code: http.StatusServiceUnavailable}
+	errUnavailable        = &HTTPError{Type: "unavailable", Title: "Service unavailable", Message: "service temporarily unavailable", Code: http.StatusServiceUnavailable}
+	errInternal           = &HTTPError{Type: "internal-error", Title: "Internal server error", Message: "internal server error", Code: http.StatusInternalServerError}
 )
 
-func handleDomainError(w http.ResponseWriter, err error) {
+func ErrInvalidQueryParam(name string) *HTTPError {
+	return &HTTPError{
+		Type:    "invalid-query-parameter",
+		Title:   "Invalid query parameter",
+		Message: fmt.Sprintf("invalid query parameter '%s'", name),
+		Code:    http.StatusBadRequest,
+	}
+}
+
+// writeDecodeError reports a request body that could not be decoded.
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	WriteError(w, r, decodeError(err))
+}
+
+// decodeError returns the response error for a request body that could
+// not be read or decoded.
+func decodeError(err error) *HTTPError {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return ErrBodyTooLarge
+	}
+	return ErrInvalidJSON
+}
+
+// WriteError maps err to an error response. The cause of every 5xx is
+// logged since the response body deliberately hides it.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	httpErr := toHTTPError(err)
+	if httpErr.Code >= http.StatusInternalServerError {
+		logServerError(r, httpErr.Code, err)
+	}
+	if httpErr.Code == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+
+	var fields validation.Errors
+	if !errors.As(err, &fields) {
+		if fieldErr, ok := constraintField(err); ok {
+			fields = validation.Errors{fieldErr}
+		}
+	}
+	writeProblem(w, r, httpErr, fields)
+}
+
+// toHTTPError is the single mapping from errors returned by the lower
+// layers to responses.
+func toHTTPError(err error) *HTTPError {
+	var (
+		httpErr        *HTTPError
+		validationErrs validation.Errors
+	)
 	switch {
+	case errors.As(err, &httpErr):
+		return httpErr
+	case errors.As(err, &validationErrs):
+		return errValidation
 	case errors.Is(err, domain.ErrNotFound):
-		WriteError(w, domain.ErrNotFound.Error(), http.StatusNotFound)
+		return errNotFound
 	case errors.Is(err, domain.ErrAlreadyExists):
-		WriteError(w, domain.ErrAlreadyExists.Error(), http.StatusConflict)
+		return withConstraintDetail(errAlreadyExists, err)
+	case errors.Is(err, domain.ErrConflict):
+		return errConflict
+	case errors.Is(err, domain.ErrValueTooLong), errors.Is(err, domain.ErrRequiredValue):
+		return withConstraintDetail(errValidation, err)
+	case errors.Is(err, domain.ErrReferenced):
+		return errReferenced
+	case errors.Is(err, domain.ErrInvalidReference):
+		return errInvalidReference
+	case errors.Is(err, patch.ErrInvalidPatch):
+		return errInvalidPatch
+	case errors.Is(err, patch.ErrCannotApply):
+		return errCannotApplyPatch
+	case errors.Is(err, patch.ErrTestFailed):
+		return errPatchTestFailed
+	case errors.Is(err, domain.ErrInvalidCursor):
+		return errInvalidCursor
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		return errInvalidCredentials
+	case errors.Is(err, domain.ErrUnauthorized):
+		// The wrapped message tells clients why the token was rejected.
+		return &HTTPError{Type: ErrMissingToken.Type, Title: ErrMissingToken.Title, Message: err.Error(), Code: http.StatusUnauthorized}
+	case errors.Is(err, domain.ErrForbidden):
+		return errForbidden
+	case errors.Is(err, domain.ErrTimeout):
+		return errTimeout
+	case errors.Is(err, domain.ErrCanceled):
+		return errCanceled
+	case errors.Is(err, domain.ErrDeadlock), errors.Is(err, domain.ErrLockTimeout):
+		return errBusy
+	case errors.Is(err, domain.ErrUnavailable), errors.Is(err, domain.ErrReadOnly):
+		return errUnavailable
 	default:
-		WriteError(w, "internal server error", http.StatusInternalServerError)
+		return errInternal
 	}
 }
+
+// constraintMessages describe a violated constraint the way
+// validation.FieldError messages do.
+var constraintMessages = map[error]string{
+	domain.ErrAlreadyExists: "already exists",
+	domain.ErrValueTooLong:  "is too long",
+	domain.ErrRequiredValue: "is required",
+}
+
+// constraintField returns the field error for a domain.ConstraintError.
+func constraintField(err error) (validation.FieldError, bool) {
+	var constraintErr *domain.ConstraintError
+	if !errors.As(err, &constraintErr) || constraintErr.Field == "" {
+		return validation.FieldError{}, false
+	}
+	message, ok := constraintMessages[constraintErr.Err]
+	if !ok {
+		message = constraintErr.Err.Error()
+	}
+	return validation.FieldError{Field: constraintErr.Field, Message: message}, true
+}
+
+// withConstraintDetail names the offending field in the detail of resp,
+// e.g. "email already exists".
+func withConstraintDetail(resp *HTTPError, err error) *HTTPError {
+	fieldErr, ok := constraintField(err)
+	if !ok {
+		return resp
+	}
+	detailed := *resp
+	detailed.Message = fieldErr.Field + " " + fieldErr.Message
+	return &detailed
+}
+
+func logServerError(r *http.Request, status int, err error) {
+	ctx := r.Context()
+	logging.FromContext(ctx).ErrorContext(ctx, "request failed", "status", status, "error", err)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"go-crud/internal/domain"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// userETag returns the strong entity tag of a user. It changes whenever
+// the user's version does.
+func userETag(u *domain.User) string {
+	return `"` + strconv.FormatInt(u.Version, 10) + `"`
+}
+
+// parseETagVersion extracts the version from a strong entity tag produced
+// by userETag.
+func parseETagVersion(tag string) (int64, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// splitETags returns the entity tags listed in the given If-Match or
+// If-None-Match header values, and whether the list was "*".
+func splitETags(values []string) ([]string, bool) {
+	var tags []string
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" {
+				return nil, true
+			}
+			if tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags, false
+}
+
+// noneMatch evaluates If-None-Match against etag using weak comparison. It
+// reports true when the header is present and one of its tags matches.
+func noneMatch(r *http.Request, etag string) bool {
+	tags, any := splitETags(r.Header.Values("If-None-Match"))
+	if any {
+		return true
+	}
+	for _, tag := range tags {
+		if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatchVersions returns the user versions listed in If-Match. Weak tags
+// never match under the strong comparison If-Match requires and are
+// skipped. ok is false when the request carries no If-Match or uses "*".
+func ifMatchVersions(r *http.Request) (versions []int64, ok bool) {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		return nil, false
+	}
+	tags, any := splitETags(values)
+	if any {
+		return nil, false
+	}
+
+	versions = []int64{}
+	for _, tag := range tags {
+		if version, valid := parseETagVersion(tag); valid {
+			versions = append(versions, version)
+		}
+	}
+	return versions, true
+}
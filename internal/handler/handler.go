@@ -3,34 +3,99 @@ package handler
 
 import (
 	"fmt"
+	"go-crud/internal/auth"
+	"go-crud/internal/authz"
 	"go-crud/internal/domain"
+	"go-crud/internal/health"
+	"go-crud/internal/metrics"
+	"go-crud/internal/password"
+	"go-crud/internal/router"
+	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 )
 
 type Dependencies struct {
-	UserRepo domain.UserRepository
+	UserRepo  domain.UserRepository
+	Tx        domain.TxManager
+	Passwords *password.Service
+	Auth      *auth.Service
+	Tokens    *auth.TokenManager
+	Policy    *authz.Policy
+	// Health holds the readiness checks; without it only the process
+	// itself is checked.
+	Health *health.Registry
+	// Metrics, when set, instruments every route and serves /metrics.
+	Metrics *metrics.Metrics
+	Logger  *slog.Logger
 }
 
 type Handler struct {
-	User *UserHandler
+	User    *UserHandler
+	Auth    *AuthHandler
+	Health  *HealthHandler
+	tokens  *auth.TokenManager
+	metrics *metrics.Metrics
+	logger  *slog.Logger
 }
 
 type MethodHandlers map[string]http.HandlerFunc
 
 func NewHandler(deps Dependencies) *Handler {
+	if deps.Logger == nil {
+		deps.Logger = slog.Default()
+	}
+	if deps.Health == nil {
+		deps.Health = health.NewRegistry(0)
+	}
 	return &Handler{
-		User: NewUserHandler(deps.UserRepo),
+		User:    NewUserHandler(deps.UserRepo, deps.Tx, deps.Passwords, deps.Policy),
+		Auth:    NewAuthHandler(deps.Auth),
+		Health:  NewHealthHandler(deps.Health),
+		tokens:  deps.Tokens,
+		metrics: deps.Metrics,
+		logger:  deps.Logger,
 	}
 }
 
-func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/users", MethodRouter(MethodHandlers{http.MethodPost: h.User.Create}))
-	mux.HandleFunc("/users/{id}", MethodRouter(MethodHandlers{
+func (h *Handler) RegisterRoutes(routes *router.Group) {
+	middlewares := []router.Middleware{withLogger(h.logger)}
+	if h.metrics != nil {
+		routes.Handle("/metrics", MethodRouter(MethodHandlers{http.MethodGet: h.metrics.Handler().ServeHTTP}))
+		middlewares = append(middlewares, Instrument(h.metrics))
+	}
+	routes = routes.Group(middlewares...)
+	authenticate := router.Middleware(Authenticate(h.tokens))
+	authenticated := func(next http.HandlerFunc) http.HandlerFunc {
+		return authenticate(next).ServeHTTP
+	}
+
+	routes.HandleFunc("/healthz", MethodRouter(MethodHandlers{http.MethodGet: h.Health.Live}))
+	routes.HandleFunc("/readyz", MethodRouter(MethodHandlers{http.MethodGet: h.Health.Ready}))
+	routes.HandleFunc("/health", MethodRouter(MethodHandlers{http.MethodGet: h.Health.Report}))
+
+	routes.HandleFunc("/auth/login", MethodRouter(MethodHandlers{http.MethodPost: h.Auth.Login}))
+	routes.HandleFunc("/auth/refresh", MethodRouter(MethodHandlers{http.MethodPost: h.Auth.Refresh}))
+	routes.HandleFunc("/auth/logout", MethodRouter(MethodHandlers{http.MethodPost: h.Auth.Logout}))
+
+	// Signing up is public, everything else under /users requires a
+	// valid access token.
+	routes.HandleFunc("/users", MethodRouter(MethodHandlers{
+		http.MethodGet:  authenticated(h.User.List),
+		http.MethodPost: h.User.Create,
+	}))
+
+	users := routes.Group(authenticate)
+	users.HandleFunc("/users/{id}", MethodRouter(MethodHandlers{
 		http.MethodGet:    h.User.GetByID,
 		http.MethodPut:    h.User.Update,
+		http.MethodPatch:  h.User.Patch,
 		http.MethodDelete: h.User.Delete,
 	}))
+	users.HandleFunc("/users/{id}/restore", MethodRouter(MethodHandlers{
+		http.MethodPost: h.User.Restore,
+	}))
 }
 
 func MethodRouter(handlers MethodHandlers) http.HandlerFunc {
@@ -39,15 +104,23 @@ func MethodRouter(handlers MethodHandlers) http.HandlerFunc {
 			h(w, r)
 			return
 		}
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methods := make([]string, 0, len(handlers))
+		for method := range handlers {
+			methods = append(methods, method)
+		}
+		slices.Sort(methods)
+		w.Header().Set("Allow", strings.Join(methods, ", "))
+		WriteError(w, r, ErrMethodNotAllowed)
 	}
 }
 
-func parsePathParam(r *http.Request, base string) (string, error) {
+// parsePathParam returns the parameter of a path made of base, the
+// parameter and then the segments of rest, e.g. /users/{id}/restore.
+func parsePathParam(r *http.Request, base string, rest ...string) (string, error) {
 	path := strings.Trim(r.URL.Path, "/")
 	parts := strings.Split(path, "/")
 
-	if len(parts) != 2 || parts[0] != base {
+	if len(parts) != 2+len(rest) || parts[0] != base || !slices.Equal(parts[2:], rest) {
 		return "", fmt.Errorf("invalid path format")
 	}
 
@@ -11,6 +11,7 @@ func TestParsePathParams(t *testing.T) {
 		name        string
 		urlPath     string
 		base        string
+		rest        []string
 		result      string
 		errExpected bool
 	}{
@@ -35,6 +36,29 @@ func TestParsePathParams(t *testing.T) {
 			result:      "",
 			errExpected: true,
 		},
+		{
+			name:        "path with suffix",
+			urlPath:     "/users/123/restore",
+			base:        "users",
+			rest:        []string{"restore"},
+			result:      "123",
+			errExpected: false,
+		},
+		{
+			name:        "suffix not matching",
+			urlPath:     "/users/123/delete",
+			base:        "users",
+			rest:        []string{"restore"},
+			result:      "",
+			errExpected: true,
+		},
+		{
+			name:        "suffix missing",
+			urlPath:     "/users/123/restore",
+			base:        "users",
+			result:      "",
+			errExpected: true,
+		},
 	}
 
 	for _, subtest := range subtests {
@@ -43,7 +67,7 @@ func TestParsePathParams(t *testing.T) {
 				URL: &url.URL{Path: subtest.urlPath},
 			}
 
-			result, err := parsePathParam(req, subtest.base)
+			result, err := parsePathParam(req, subtest.base, subtest.rest...)
 
 			if subtest.result != result {
 				t.Errorf("expected: %v, got: %v", subtest.result, result)
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"go-crud/internal/health"
+	"go-crud/internal/logging"
+	"net/http"
+)
+
+// HealthHandler serves the probes of the orchestrator and the detailed
+// health report.
+type HealthHandler struct {
+	checks *health.Registry
+}
+
+func NewHealthHandler(checks *health.Registry) *HealthHandler {
+	return &HealthHandler{checks: checks}
+}
+
+// Live reports that the process is up. It checks no dependencies, so a
+// failing database does not get the process restarted.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	WriteResponse(w, map[string]string{"status": health.StatusOK}, http.StatusOK)
+}
+
+// Ready reports whether the service can take traffic.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.check(r)
+	w.Header().Set("Cache-Control", "no-store")
+	WriteResponse(w, map[string]string{"status": report.Status}, reportStatusCode(report))
+}
+
+// Report returns the status and latency of every dependency. The error
+// details only go to the log, as the endpoint is public.
+func (h *HealthHandler) Report(w http.ResponseWriter, r *http.Request) {
+	report := h.check(r)
+	w.Header().Set("Cache-Control", "no-store")
+	WriteResponse(w, report, reportStatusCode(report))
+}
+
+// check runs the checks and logs why any of them failed.
+func (h *HealthHandler) check(r *http.Request) *health.Report {
+	report := h.checks.Check(r.Context())
+	for name, result := range report.Checks {
+		if result.Err != nil {
+			logging.FromContext(r.Context()).WarnContext(r.Context(), "health check failed", "check", name, "error", result.Err)
+		}
+	}
+	return report
+}
+
+func reportStatusCode(report *health.Report) int {
+	if report.OK() {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"go-crud/internal/health"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var latencyPattern = regexp.MustCompile(`"latency":"[^"]*"`)
+
+func TestHealthHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		dbErr      error
+		draining   bool
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "live", path: "/healthz", dbErr: errors.New("down"), wantStatus: http.StatusOK, wantBody: `{"status":"ok"}`},
+		{name: "ready", path: "/readyz", wantStatus: http.StatusOK, wantBody: `{"status":"ok"}`},
+		{name: "not ready", path: "/readyz", dbErr: errors.New("down"), wantStatus: http.StatusServiceUnavailable, wantBody: `{"status":"fail"}`},
+		{name: "draining", path: "/readyz", draining: true, wantStatus: http.StatusServiceUnavailable, wantBody: `{"status":"fail"}`},
+		{
+			name:       "report",
+			path:       "/health",
+			dbErr:      errors.New("down"),
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"status":"fail","checks":{"database":{"status":"fail","latency":"<latency>","error":"check failed"}}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checks := health.NewRegistry(0)
+			checks.Register("database", health.CheckerFunc(func(ctx context.Context) error { return test.dbErr }))
+			if test.draining {
+				checks.Drain()
+			}
+			h := NewHealthHandler(checks)
+			mux := http.NewServeMux()
+			mux.HandleFunc("/healthz", h.Live)
+			mux.HandleFunc("/readyz", h.Ready)
+			mux.HandleFunc("/health", h.Report)
+
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			if test.wantStatus != w.Code {
+				t.Errorf("expected status code: %v, got: %v", test.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("expected Cache-Control: no-store, got: %q", got)
+			}
+			body := latencyPattern.ReplaceAllString(strings.TrimSpace(w.Body.String()), `"latency":"<latency>"`)
+			if body != test.wantBody {
+				t.Errorf("expected response body: %s, got: %s", test.wantBody, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"go-crud/internal/logging"
+	"go-crud/internal/metrics"
+	"go-crud/internal/tracing"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID propagates the X-Request-ID header of the request, or a newly
+// generated ID when it is missing or malformed, to the request context and
+// the response headers.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		ctx = logging.WithAttrs(ctx, slog.String("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Recover turns a panic in a handler into a 500 response with the usual
+// JSON error body.
+func Recover(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				// ErrAbortHandler is how handlers deliberately abort a
+				// response; net/http handles it without logging.
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+
+				logger.ErrorContext(r.Context(), "panic serving request",
+					"status", http.StatusInternalServerError,
+					"panic", rec,
+					"stack", string(debug.Stack()),
+				)
+				writeProblem(w, r, errInternal, nil)
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// AccessLog logs one record per request once the response has been
+// written.
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			next.ServeHTTP(rec, r)
+
+			// r.Pattern is filled in by the mux once a route matched.
+			logger.InfoContext(r.Context(), "request",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", r.Pattern,
+				"status", rec.status,
+				"bytes", rec.bytes,
+				"duration", time.Since(start),
+			)
+		})
+	}
+}
+
+// Trace runs every request in a server span, continuing the caller's trace
+// when the request carries a W3C traceparent header. Log records of the
+// request are tagged with the trace ID.
+func Trace(next http.Handler) http.Handler {
+	tracer := tracing.Tracer()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		method := metricMethod(r.Method)
+		ctx, span := tracer.Start(ctx, method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.URLPath(r.URL.Path),
+				attribute.String("request.id", RequestIDFromContext(ctx)),
+			),
+		)
+		defer span.End()
+
+		if sc := span.SpanContext(); sc.IsValid() {
+			ctx = logging.WithAttrs(ctx, slog.String("trace_id", sc.TraceID().String()))
+		}
+
+		// The mux fills in the pattern of the request it is handed.
+		r = r.WithContext(ctx)
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if r.Pattern != "" {
+			// http.route is the path template, without the method a
+			// pattern may start with.
+			route := r.Pattern
+			if _, path, ok := strings.Cut(route, " "); ok {
+				route = path
+			}
+			span.SetName(method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.status))
+		if rec.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.status))
+		}
+	})
+}
+
+// withLogger makes logger available to the route handlers and tags their
+// log records with the matched route pattern.
+func withLogger(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := logging.NewContext(r.Context(), logger)
+			ctx = logging.WithAttrs(ctx, slog.String("route", r.Pattern))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Instrument records the count and latency of the requests served by the
+// route it wraps, labeled with the route pattern.
+func Instrument(m *metrics.Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			defer func() {
+				// Recover turns panics into 500 responses further out.
+				if p := recover(); p != nil {
+					m.ObserveRequest(r.Pattern, metricMethod(r.Method), http.StatusInternalServerError, time.Since(start))
+					panic(p)
+				}
+				m.ObserveRequest(r.Pattern, metricMethod(r.Method), rec.status, time.Since(start))
+			}()
+
+			next.ServeHTTP(rec, r)
+		})
+	}
+}
+
+// metricMethod maps methods outside the standard set to a single label
+// value, so clients cannot grow the label set.
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	default:
+		return "OTHER"
+	}
+}
+
+// MaxBodySize rejects request bodies larger than limit bytes.
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				WriteError(w, r, ErrBodyTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// responseRecorder captures the status code and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"go-crud/internal/logging"
+	"go-crud/internal/metrics"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestRecover(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	Recover(logging.Discard())(panicking).ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status code: %v, got: %v", http.StatusInternalServerError, w.Code)
+	}
+	if got := strings.TrimSpace(w.Body.String()); got != `{"error":"internal server error"}` {
+		t.Errorf("unexpected response body: %s", got)
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{name: "propagated", incoming: "abc-123", wantSame: true},
+		{name: "generated when missing", incoming: "", wantSame: false},
+		{name: "generated when malformed", incoming: "has spaces", wantSame: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fromContext string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromContext = RequestIDFromContext(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.incoming != "" {
+				req.Header.Set(RequestIDHeader, test.incoming)
+			}
+			w := httptest.NewRecorder()
+			RequestID(next).ServeHTTP(w, req)
+
+			header := w.Header().Get(RequestIDHeader)
+			if header == "" || header != fromContext {
+				t.Errorf("expected matching request ids, header: %q, context: %q", header, fromContext)
+			}
+			if (header == test.incoming) != test.wantSame {
+				t.Errorf("unexpected request id %q for incoming %q", header, test.incoming)
+			}
+		})
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	repo := &mockUserRepo{}
+	handler := MaxBodySize(64)(http.HandlerFunc(NewUserHandler(repo, testTx(repo), testPasswords, testPolicy).Create))
+
+	tests := []struct {
+		name       string
+		body       io.Reader
+		wantStatus int
+	}{
+		{
+			name:       "declared length too large",
+			body:       strings.NewReader(`{"username":"` + strings.Repeat("a", 100) + `"}`),
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "streamed body too large",
+			body:       io.MultiReader(strings.NewReader(`{"username":"`), strings.NewReader(strings.Repeat("a", 100)+`"}`)),
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:       "within limit",
+			body:       strings.NewReader(`{}`),
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/users", test.body)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != test.wantStatus {
+				t.Errorf("expected status code: %v, got: %v (%s)", test.wantStatus, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	m := metrics.New()
+	mux := http.NewServeMux()
+	mux.Handle("/users/{id}", Instrument(m)(MethodRouter(MethodHandlers{
+		http.MethodGet: func(w http.ResponseWriter, r *http.Request) {
+			if r.PathValue("id") == "0" {
+				panic("boom")
+			}
+			w.WriteHeader(http.StatusNoContent)
+		},
+	})))
+	handler := Recover(logging.Discard())(mux)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/users/1", nil),
+		httptest.NewRequest(http.MethodGet, "/users/2", nil),
+		httptest.NewRequest(http.MethodPost, "/users/1", nil),
+		httptest.NewRequest("PURGE", "/users/1", nil),
+		httptest.NewRequest(http.MethodGet, "/users/0", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="/users/{id}",status="204"} 2`,
+		`http_requests_total{method="POST",route="/users/{id}",status="405"} 1`,
+		`http_requests_total{method="OTHER",route="/users/{id}",status="405"} 1`,
+		`http_requests_total{method="GET",route="/users/{id}",status="500"} 1`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "0" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	handler := Trace(mux)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		traceparent string
+		wantName    string
+		wantStatus  codes.Code
+	}{
+		{name: "named by route", path: "/users/1", wantName: "GET /users/{id}", wantStatus: codes.Unset},
+		{name: "continues the incoming trace", path: "/users/1", traceparent: "00-" + traceID + "-00f067aa0ba902b7-01", wantName: "GET /users/{id}", wantStatus: codes.Unset},
+		{name: "server errors", path: "/users/0", wantName: "GET /users/{id}", wantStatus: codes.Error},
+		{name: "unmatched requests keep the method", path: "/unknown", wantName: "GET", wantStatus: codes.Unset},
+		{name: "unknown methods", method: "PURGE", path: "/unknown", wantName: "OTHER", wantStatus: codes.Unset},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder.Reset()
+			method := test.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, test.path, nil)
+			if test.traceparent != "" {
+				req.Header.Set("traceparent", test.traceparent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("expected 1 span, got: %d", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != test.wantName {
+				t.Errorf("expected span name: %q, got: %q", test.wantName, span.Name())
+			}
+			if got := span.Status().Code; got != test.wantStatus {
+				t.Errorf("expected status: %v, got: %v", test.wantStatus, got)
+			}
+			if test.traceparent != "" {
+				if got := span.SpanContext().TraceID().String(); got != traceID {
+					t.Errorf("expected trace id: %s, got: %s", traceID, got)
+				}
+				if !span.Parent().IsRemote() {
+					t.Errorf("expected a remote parent")
+				}
+			}
+		})
+	}
+}
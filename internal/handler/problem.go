@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"encoding/json"
+	"go-crud/internal/validation"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	ProblemContentType = "application/problem+json"
+
+	// ProblemTypeBase prefixes the Type of every HTTPError to form the
+	// problem type URI.
+	ProblemTypeBase = "urn:go-crud:problem:"
+)
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Fields lists the violations of a validation problem.
+	Fields []validation.FieldError `json:"fields,omitempty"`
+}
+
+type legacyErrorResponse struct {
+	Error  string                  `json:"error"`
+	Fields []validation.FieldError `json:"fields,omitempty"`
+}
+
+// writeProblem writes httpErr as problem details, or in the legacy
+// {"error": "..."} shape when the client prefers plain JSON.
+func writeProblem(w http.ResponseWriter, r *http.Request, httpErr *HTTPError, fields validation.Errors) {
+	w.Header().Add("Vary", "Accept")
+
+	if prefersLegacyErrors(r) {
+		WriteResponse(w, legacyErrorResponse{Error: httpErr.Message, Fields: fields}, httpErr.Code)
+		return
+	}
+
+	problem := Problem{
+		Type:      ProblemTypeBase + httpErr.Type,
+		Title:     httpErr.Title,
+		Status:    httpErr.Code,
+		Detail:    httpErr.Message,
+		Instance:  r.URL.Path,
+		RequestID: RequestIDFromContext(r.Context()),
+		Fields:    fields,
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(httpErr.Code)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// prefersLegacyErrors reports whether the Accept header ranks
+// application/json above application/problem+json. Clients that send no
+// Accept header, or accept both equally, get problem details.
+func prefersLegacyErrors(r *http.Request) bool {
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return false
+	}
+	return acceptQuality(accept, "application/json") > acceptQuality(accept, ProblemContentType)
+}
+
+// acceptQuality returns the quality the most specific matching media range
+// of an Accept header assigns to mediaType, or 0 when none matches.
+func acceptQuality(accept []string, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+
+	quality, specificity := 0.0, -1
+	for _, line := range accept {
+		for _, item := range strings.Split(line, ",") {
+			rng, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+			if err != nil {
+				continue
+			}
+
+			var s int
+			switch rng {
+			case mediaType:
+				s = 2
+			case typ + "/*":
+				s = 1
+			case "*/*":
+				s = 0
+			default:
+				continue
+			}
+			if s <= specificity {
+				continue
+			}
+
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+			quality, specificity = q, s
+		}
+	}
+	return quality
+}
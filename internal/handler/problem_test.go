@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-crud/internal/domain"
+	"go-crud/internal/validation"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestWriteError_Problem(t *testing.T) {
+	subtests := []struct {
+		name string
+		err  error
+		want Problem
+	}{
+		{
+			name: "domain error",
+			err:  fmt.Errorf("lookup: %w", domain.ErrNotFound),
+			want: Problem{
+				Type:   ProblemTypeBase + "not-found",
+				Title:  "Resource not found",
+				Status: http.StatusNotFound,
+				Detail: "resource not found",
+			},
+		},
+		{
+			name: "http error",
+			err:  ErrInvalidQueryParam("limit"),
+			want: Problem{
+				Type:   ProblemTypeBase + "invalid-query-parameter",
+				Title:  "Invalid query parameter",
+				Status: http.StatusBadRequest,
+				Detail: "invalid query parameter 'limit'",
+			},
+		},
+		{
+			name: "validation errors",
+			err:  validation.Errors{{Field: "email", Message: "must not be blank"}},
+			want: Problem{
+				Type:   ProblemTypeBase + "validation-failed",
+				Title:  "Validation failed",
+				Status: http.StatusUnprocessableEntity,
+				Detail: "validation failed",
+				Fields: []validation.FieldError{{Field: "email", Message: "must not be blank"}},
+			},
+		},
+		{
+			name: "duplicate field",
+			err:  &domain.ConstraintError{Field: "email", Err: domain.ErrAlreadyExists},
+			want: Problem{
+				Type:   ProblemTypeBase + "already-exists",
+				Title:  "Resource already exists",
+				Status: http.StatusConflict,
+				Detail: "email already exists",
+				Fields: []validation.FieldError{{Field: "email", Message: "already exists"}},
+			},
+		},
+		{
+			name: "value too long",
+			err:  &domain.ConstraintError{Field: "username", Err: domain.ErrValueTooLong},
+			want: Problem{
+				Type:   ProblemTypeBase + "validation-failed",
+				Title:  "Validation failed",
+				Status: http.StatusUnprocessableEntity,
+				Detail: "username is too long",
+				Fields: []validation.FieldError{{Field: "username", Message: "is too long"}},
+			},
+		},
+		{
+			name: "deadlock",
+			err:  fmt.Errorf("%w: lock conflict", domain.ErrDeadlock),
+			want: Problem{
+				Type:   ProblemTypeBase + "busy",
+				Title:  "Resource busy",
+				Status: http.StatusServiceUnavailable,
+				Detail: "resource is busy, try again later",
+			},
+		},
+		{
+			name: "canceled",
+			err:  fmt.Errorf("%w: context canceled", domain.ErrCanceled),
+			want: Problem{
+				Type:   ProblemTypeBase + "canceled",
+				Title:  "Request canceled",
+				Status: 499,
+				Detail: "operation canceled",
+			},
+		},
+		{
+			name: "unexpected error",
+			err:  fmt.Errorf("connection reset"),
+			want: Problem{
+				Type:   ProblemTypeBase + "internal-error",
+				Title:  "Internal server error",
+				Status: http.StatusInternalServerError,
+				Detail: "internal server error",
+			},
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users/7", nil)
+			req = req.WithContext(context.WithValue(req.Context(), requestIDKey{}, "req-1"))
+			w := httptest.NewRecorder()
+
+			WriteError(w, req, subtest.err)
+
+			if w.Code != subtest.want.Status {
+				t.Errorf("expected status code: %v, got: %v", subtest.want.Status, w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != ProblemContentType {
+				t.Errorf("expected content type: %v, got: %v", ProblemContentType, got)
+			}
+
+			var got Problem
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("failed to decode problem: %v", err)
+			}
+			subtest.want.Instance = "/users/7"
+			subtest.want.RequestID = "req-1"
+			if !reflect.DeepEqual(got, subtest.want) {
+				t.Errorf("expected problem: %+v, got: %+v", subtest.want, got)
+			}
+		})
+	}
+}
+
+func TestPrefersLegacyErrors(t *testing.T) {
+	subtests := []struct {
+		name   string
+		accept []string
+		want   bool
+	}{
+		{name: "no accept header", want: false},
+		{name: "any", accept: []string{"*/*"}, want: false},
+		{name: "plain json", accept: []string{"application/json"}, want: true},
+		{name: "both", accept: []string{"application/json, application/problem+json"}, want: false},
+		{name: "problem ranked lower", accept: []string{"application/problem+json;q=0.5, application/json"}, want: true},
+		{name: "json ranked lower", accept: []string{"application/json;q=0.9", "application/*"}, want: false},
+		{name: "unrelated type", accept: []string{"text/html"}, want: false},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			for _, accept := range subtest.accept {
+				req.Header.Add("Accept", accept)
+			}
+			if got := prefersLegacyErrors(req); got != subtest.want {
+				t.Errorf("expected: %v, got: %v", subtest.want, got)
+			}
+		})
+	}
+}
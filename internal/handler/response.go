@@ -10,8 +10,3 @@ func WriteResponse(w http.ResponseWriter, data any, statusCode int) {
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(data)
 }
-
-func WriteError(w http.ResponseWriter, message string, statusCode int) {
-	response := map[string]string{"error": message}
-	WriteResponse(w, response, statusCode)
-}
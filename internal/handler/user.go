@@ -1,32 +1,75 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"go-crud/internal/auth"
+	"go-crud/internal/authz"
 	"go-crud/internal/domain"
+	"go-crud/internal/password"
+	"go-crud/internal/patch"
+	"go-crud/internal/validation"
+	"io"
+	"mime"
 	"net/http"
+	"slices"
 	"strconv"
 )
 
 type UserHandler struct {
-	userRepo domain.UserRepository
+	userRepo  domain.UserRepository
+	tx        domain.TxManager
+	passwords *password.Service
+	policy    *authz.Policy
 }
 
-func NewUserHandler(userRepository domain.UserRepository) *UserHandler {
+func NewUserHandler(userRepository domain.UserRepository, tx domain.TxManager, passwords *password.Service, policy *authz.Policy) *UserHandler {
 	return &UserHandler{
-		userRepo: userRepository,
+		userRepo:  userRepository,
+		tx:        tx,
+		passwords: passwords,
+		policy:    policy,
 	}
 }
 
+// createUserRequest mirrors domain.User for decoding, since the domain
+// type never (un)marshals its password.
+type createUserRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
 func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
-	var user domain.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		WriteError(w, ErrInvalidJSON.Message, ErrInvalidJSON.Code)
+	var req createUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	user := domain.User{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: req.Password,
+		Role:     domain.RoleUser,
+	}
+	if err := validation.User(&user); err != nil {
+		WriteError(w, r, err)
 		return
 	}
 
-	err := h.userRepo.Create(&user)
+	hash, err := h.hashPassword(w, r, user.Password)
 	if err != nil {
-		handleDomainError(w, err)
+		return
+	}
+	user.Password = hash
+
+	err = h.userRepo.Create(r.Context(), &user)
+	if err != nil {
+		WriteError(w, r, err)
 		return
 	}
 
@@ -36,79 +79,311 @@ func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
 func (h *UserHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 	idStr, err := parsePathParam(r, "users")
 	if err != nil {
-		WriteError(w, ErrInvalidPath.Message, ErrInvalidPath.Code)
+		WriteError(w, r, ErrInvalidPath)
 		return
 	}
 
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		WriteError(w, ErrInvalidID.Message, ErrInvalidID.Code)
+		WriteError(w, r, ErrInvalidID)
 		return
 	}
 
-	user, err := h.userRepo.GetByID(id)
+	if !h.authorize(w, r, authz.ActionReadUser, id) {
+		return
+	}
+
+	user, err := h.userRepo.GetByID(r.Context(), id)
 	if err != nil {
-		handleDomainError(w, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	etag := userETag(user)
+	w.Header().Set("ETag", etag)
+	if noneMatch(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	WriteResponse(w, user, http.StatusOK)
 }
 
+// Update replaces the writable fields of a user. Fields missing from the
+// body are treated as empty, except the write-only password which is only
+// changed when given. The read-only fields are ignored, so a fetched user
+// can be sent back as is.
 func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
+	h.modify(w, r, func(current *domain.User) (*userDocument, error) {
+		var doc replaceDocument
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&doc); err != nil {
+			return nil, decodeError(err)
+		}
+		return &doc.userDocument, nil
+	})
+}
+
+// Patch applies a JSON Merge Patch or JSON Patch document, chosen by the
+// request content type, to the writable fields of a user.
+func (h *UserHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var apply func(doc, p []byte) ([]byte, error)
+	switch mediaType {
+	case patch.MergePatchContentType:
+		apply = patch.Merge
+	case patch.JSONPatchContentType:
+		apply = patch.Apply
+	default:
+		w.Header().Set("Accept-Patch", patch.MergePatchContentType+", "+patch.JSONPatchContentType)
+		WriteError(w, r, ErrUnsupportedMediaType)
+		return
+	}
+
+	h.modify(w, r, func(current *domain.User) (*userDocument, error) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, decodeError(err)
+		}
+
+		original, err := json.Marshal(newUserDocument(current))
+		if err != nil {
+			return nil, err
+		}
+		patched, err := apply(original, body)
+		if err != nil {
+			return nil, err
+		}
+
+		var doc userDocument
+		dec := json.NewDecoder(bytes.NewReader(patched))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("%w: %w", patch.ErrCannotApply, err)
+		}
+		return &doc, nil
+	})
+}
+
+// modify implements PUT and PATCH: build derives the new document from
+// the current user, and only the fields that differ are written. The
+// write is conditional on the version that was read, so concurrent
+// modifications are detected even without If-Match.
+func (h *UserHandler) modify(w http.ResponseWriter, r *http.Request, build func(current *domain.User) (*userDocument, error)) {
 	idStr, err := parsePathParam(r, "users")
 	if err != nil {
-		WriteError(w, ErrInvalidPath.Message, ErrInvalidPath.Code)
+		WriteError(w, r, ErrInvalidPath)
 		return
 	}
 
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		WriteError(w, ErrInvalidID.Message, ErrInvalidID.Code)
+		WriteError(w, r, ErrInvalidID)
 		return
 	}
 
-	var userUpd domain.UserUpdate
-	dec := json.NewDecoder(r.Body)
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(&userUpd); err != nil {
-		WriteError(w, ErrInvalidJSON.Message, ErrInvalidJSON.Code)
+	if !h.authorize(w, r, authz.ActionUpdateUser, id) {
 		return
 	}
 
-	err = h.userRepo.Update(id, &userUpd)
+	current, err := h.userRepo.GetByID(r.Context(), id)
 	if err != nil {
-		handleDomainError(w, err)
+		WriteError(w, r, err)
+		return
+	}
+
+	versions, conditional := ifMatchVersions(r)
+	if conditional && !slices.Contains(versions, current.Version) {
+		WriteError(w, r, ErrPreconditionFailed)
+		return
+	}
+
+	doc, err := build(current)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	userUpd := doc.changes(current)
+	if err := validation.UserUpdate(userUpd); err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	if userUpd.Role != nil && !h.authorize(w, r, authz.ActionChangeRole, id) {
 		return
 	}
 
-	user, err := h.userRepo.GetByID(id)
+	if userUpd.Password != nil {
+		hash, err := h.hashPassword(w, r, *userUpd.Password)
+		if err != nil {
+			return
+		}
+		userUpd.Password = &hash
+	}
+
+	userUpd.Version = &current.Version
+	user, err := h.writeAndRead(r.Context(), id, func(ctx context.Context, users domain.UserRepository) error {
+		return users.Update(ctx, id, userUpd)
+	})
+	if conditional && errors.Is(err, domain.ErrConflict) {
+		err = ErrPreconditionFailed
+	}
 	if err != nil {
-		handleDomainError(w, err)
+		WriteError(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", userETag(user))
 	WriteResponse(w, user, http.StatusOK)
 }
 
+// userDocument is the writable representation of a user that PUT replaces
+// and PATCH edits. The password is write-only, so it is never part of the
+// document a patch is applied to.
+type userDocument struct {
+	Username string      `json:"username"`
+	Email    string      `json:"email"`
+	Role     domain.Role `json:"role"`
+	Password *string     `json:"password,omitempty"`
+}
+
+// replaceDocument is the body of a PUT: a userDocument that may also carry
+// the read-only fields of domain.User, which are ignored.
+type replaceDocument struct {
+	userDocument
+	ID        json.RawMessage `json:"id"`
+	Version   json.RawMessage `json:"version"`
+	CreatedAt json.RawMessage `json:"created_at"`
+	UpdatedAt json.RawMessage `json:"updated_at"`
+	DeletedAt json.RawMessage `json:"deleted_at"`
+}
+
+func newUserDocument(u *domain.User) *userDocument {
+	return &userDocument{Username: u.Username, Email: u.Email, Role: u.Role}
+}
+
+// changes returns the update turning current into d.
+func (d *userDocument) changes(current *domain.User) *domain.UserUpdate {
+	upd := &domain.UserUpdate{Password: d.Password}
+	if d.Username != current.Username {
+		upd.Username = &d.Username
+	}
+	if d.Email != current.Email {
+		upd.Email = &d.Email
+	}
+	if d.Role != current.Role {
+		upd.Role = &d.Role
+	}
+	return upd
+}
+
 func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	idStr, err := parsePathParam(r, "users")
 	if err != nil {
-		WriteError(w, ErrInvalidPath.Message, ErrInvalidPath.Code)
+		WriteError(w, r, ErrInvalidPath)
 		return
 	}
 
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		WriteError(w, ErrInvalidID.Message, ErrInvalidID.Code)
+		WriteError(w, r, ErrInvalidID)
+		return
+	}
+
+	// Deletes are soft unless ?hard=true, which only admins may use.
+	hard, httpErr := parseBoolParam(r.URL.Query(), "hard")
+	if httpErr != nil {
+		WriteError(w, r, httpErr)
 		return
 	}
 
-	err = h.userRepo.Delete(id)
+	action, remove := authz.ActionDeleteUser, h.userRepo.Delete
+	if hard {
+		action, remove = authz.ActionHardDeleteUser, h.userRepo.HardDelete
+	}
+	if !h.authorize(w, r, action, id) {
+		return
+	}
+
+	err = remove(r.Context(), id)
 	if err != nil {
-		handleDomainError(w, err)
+		WriteError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Restore undoes a soft delete and returns the restored user.
+func (h *UserHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	idStr, err := parsePathParam(r, "users", "restore")
+	if err != nil {
+		WriteError(w, r, ErrInvalidPath)
+		return
+	}
+
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		WriteError(w, r, ErrInvalidID)
+		return
+	}
+
+	if !h.authorize(w, r, authz.ActionRestoreUser, id) {
+		return
+	}
+
+	user, err := h.writeAndRead(r.Context(), id, func(ctx context.Context, users domain.UserRepository) error {
+		return users.Restore(ctx, id)
+	})
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", userETag(user))
+	WriteResponse(w, user, http.StatusOK)
+}
+
+// writeAndRead runs write and reads the user back in one transaction, so
+// the response shows exactly the state the write produced.
+func (h *UserHandler) writeAndRead(ctx context.Context, id int64, write func(ctx context.Context, users domain.UserRepository) error) (*domain.User, error) {
+	var user *domain.User
+	err := h.tx.WithinTx(ctx, func(ctx context.Context, repos domain.Repositories) error {
+		if err := write(ctx, repos.Users); err != nil {
+			return err
+		}
+		var err error
+		user, err = repos.Users.GetByID(ctx, id)
+		return err
+	})
+	return user, err
+}
+
+// authorize checks the request principal against the policy, writing the
+// error response itself when the action is not allowed.
+func (h *UserHandler) authorize(w http.ResponseWriter, r *http.Request, action authz.Action, targetID int64) bool {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	if err := h.policy.Authorize(principal, action, targetID); err != nil {
+		WriteError(w, r, err)
+		return false
+	}
+	return true
+}
+
+// hashPassword hashes a plaintext password, writing the error response
+// itself when hashing fails.
+func (h *UserHandler) hashPassword(w http.ResponseWriter, r *http.Request, plain string) (string, error) {
+	hash, err := h.passwords.Hash(plain)
+	if errors.Is(err, password.ErrTooLong) {
+		WriteError(w, r, ErrPasswordTooLong)
+		return "", err
+	}
+	if err != nil {
+		WriteError(w, r, err)
+		return "", err
+	}
+	return hash, nil
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"go-crud/internal/authz"
+	"go-crud/internal/domain"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+type listUsersResponse struct {
+	Data       []domain.User `json:"data"`
+	Pagination pagination    `json:"pagination"`
+}
+
+type pagination struct {
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
+
+// List serves GET /users. Supported query parameters:
+//
+//	limit, offset         page size (max 100) and offset
+//	cursor                opaque next_cursor from a previous page
+//	sort                  id, username, email, created_at or updated_at; prefix with '-' for descending
+//	email_prefix          filter by email prefix
+//	username_prefix       filter by username prefix
+//	created_after/before  RFC 3339 created_at range, inclusive/exclusive
+//	include_total         set to true to include the total match count
+//	deleted               set to true to list soft-deleted users instead
+func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
+	if !h.authorize(w, r, authz.ActionListUsers, 0) {
+		return
+	}
+
+	params, httpErr := parseUserListParams(r.URL.Query())
+	if httpErr != nil {
+		WriteError(w, r, httpErr)
+		return
+	}
+
+	list, err := h.userRepo.List(r.Context(), params)
+	if err != nil {
+		WriteError(w, r, err)
+		return
+	}
+
+	resp := listUsersResponse{
+		Data: list.Users,
+		Pagination: pagination{
+			Limit:  params.Limit,
+			Offset: params.Offset,
+			Total:  list.Total,
+		},
+	}
+	if list.NextCursor != nil {
+		resp.Pagination.NextCursor = list.NextCursor.Encode()
+	}
+
+	WriteResponse(w, resp, http.StatusOK)
+}
+
+func parseUserListParams(q url.Values) (domain.UserListParams, *HTTPError) {
+	params := domain.UserListParams{
+		SortBy: domain.SortByID,
+		Limit:  defaultListLimit,
+		Filter: domain.UserFilter{
+			EmailPrefix:    q.Get("email_prefix"),
+			UsernamePrefix: q.Get("username_prefix"),
+		},
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			return params, ErrInvalidQueryParam("limit")
+		}
+		params.Limit = limit
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return params, ErrInvalidQueryParam("offset")
+		}
+		params.Offset = offset
+	}
+
+	if v := q.Get("sort"); v != "" {
+		field := strings.TrimPrefix(v, "-")
+		if !domain.UserSortFields[domain.UserSortField(field)] {
+			return params, ErrInvalidQueryParam("sort")
+		}
+		params.SortBy = domain.UserSortField(field)
+		params.SortDesc = strings.HasPrefix(v, "-")
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		if params.Offset != 0 {
+			return params, ErrInvalidQueryParam("cursor")
+		}
+		cursor, err := domain.DecodeUserCursor(v)
+		if err != nil {
+			return params, ErrInvalidQueryParam("cursor")
+		}
+		if q.Get("sort") == "" {
+			params.SortBy = cursor.SortBy
+			params.SortDesc = cursor.SortDesc
+		}
+		params.Cursor = cursor
+	}
+
+	var httpErr *HTTPError
+	if params.Filter.CreatedAfter, httpErr = parseTimeParam(q, "created_after"); httpErr != nil {
+		return params, httpErr
+	}
+	if params.Filter.CreatedBefore, httpErr = parseTimeParam(q, "created_before"); httpErr != nil {
+		return params, httpErr
+	}
+
+	if params.IncludeTotal, httpErr = parseBoolParam(q, "include_total"); httpErr != nil {
+		return params, httpErr
+	}
+	if params.Filter.Deleted, httpErr = parseBoolParam(q, "deleted"); httpErr != nil {
+		return params, httpErr
+	}
+
+	return params, nil
+}
+
+func parseBoolParam(q url.Values, name string) (bool, *HTTPError) {
+	v := q.Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, ErrInvalidQueryParam(name)
+	}
+	return b, nil
+}
+
+func parseTimeParam(q url.Values, name string) (*time.Time, *HTTPError) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, ErrInvalidQueryParam(name)
+	}
+	return &t, nil
+}
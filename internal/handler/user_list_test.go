@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"go-crud/internal/domain"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUserHandler_List(t *testing.T) {
+	total := int64(2)
+	nextCursor := &domain.UserCursor{SortBy: domain.SortByID, ID: 1}
+	createdAfter := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		query      string
+		repoList   *domain.UserList
+		repoErr    error
+		wantStatus int
+		wantBody   string
+		wantParams *domain.UserListParams
+	}{
+		{
+			name:  "defaults",
+			query: "",
+			repoList: &domain.UserList{
+				Users: []domain.User{{ID: 1, Email: "a@email.com", Username: "a"}},
+			},
+			wantStatus: http.StatusOK,
+			wantParams: &domain.UserListParams{SortBy: domain.SortByID, Limit: defaultListLimit},
+		},
+		{
+			name:  "filters, sort and total",
+			query: "?limit=1&sort=-created_at&email_prefix=a&username_prefix=b&created_after=2024-01-01T00:00:00Z&include_total=true",
+			repoList: &domain.UserList{
+				Users:      []domain.User{{ID: 1, Email: "a@email.com", Username: "b"}},
+				NextCursor: nextCursor,
+				Total:      &total,
+			},
+			wantStatus: http.StatusOK,
+			wantParams: &domain.UserListParams{
+				Filter: domain.UserFilter{
+					EmailPrefix:    "a",
+					UsernamePrefix: "b",
+					CreatedAfter:   &createdAfter,
+				},
+				SortBy:       domain.SortByCreatedAt,
+				SortDesc:     true,
+				Limit:        1,
+				IncludeTotal: true,
+			},
+		},
+		{
+			name:  "cursor",
+			query: "?cursor=" + nextCursor.Encode(),
+			repoList: &domain.UserList{
+				Users: []domain.User{},
+			},
+			wantStatus: http.StatusOK,
+			wantParams: &domain.UserListParams{SortBy: domain.SortByID, Limit: defaultListLimit, Cursor: nextCursor},
+		},
+		{
+			name:       "limit too large",
+			query:      "?limit=1000",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"invalid query parameter 'limit'"}`,
+		},
+		{
+			name:       "sort column not allowed",
+			query:      "?sort=password",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"invalid query parameter 'sort'"}`,
+		},
+		{
+			name:       "cursor with offset",
+			query:      "?offset=10&cursor=" + nextCursor.Encode(),
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"invalid query parameter 'cursor'"}`,
+		},
+		{
+			name:       "malformed cursor",
+			query:      "?cursor=not-a-cursor",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"invalid query parameter 'cursor'"}`,
+		},
+		{
+			name:       "invalid created_before",
+			query:      "?created_before=yesterday",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"invalid query parameter 'created_before'"}`,
+		},
+		{
+			name:       "cursor rejected by repository",
+			query:      "",
+			repoErr:    domain.ErrInvalidCursor,
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":"invalid pagination cursor"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotParams *domain.UserListParams
+			repo := &mockUserRepo{
+				listFunc: func(params domain.UserListParams) (*domain.UserList, error) {
+					gotParams = &params
+					return test.repoList, test.repoErr
+				},
+			}
+			handler := NewUserHandler(repo, testTx(repo), testPasswords, testPolicy)
+			req := httptest.NewRequest(http.MethodGet, "/users"+test.query, nil)
+			req.Header.Set("Accept", "application/json")
+			req = withPrincipal(req, 1, domain.RoleAdmin)
+			w := httptest.NewRecorder()
+			handler.List(w, req)
+			resp := w.Result()
+			defer resp.Body.Close()
+			if test.wantStatus != resp.StatusCode {
+				t.Errorf("expected status code: %v, got: %v", test.wantStatus, resp.StatusCode)
+			}
+			respBody, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read response body: %v", err)
+			}
+			respBodyStr := string(respBody)
+			if test.wantBody != "" && strings.TrimSpace(respBodyStr) != test.wantBody {
+				t.Errorf("expected response body: %s, got: %s", test.wantBody, respBodyStr)
+			}
+			if test.wantParams != nil && !reflect.DeepEqual(test.wantParams, gotParams) {
+				t.Errorf("expected params: %+v, got: %+v", test.wantParams, gotParams)
+			}
+			if test.name == "filters, sort and total" {
+				checkResponseFields(t, respBodyStr, map[string]any{
+					"pagination": map[string]any{
+						"limit":       1,
+						"offset":      0,
+						"next_cursor": nextCursor.Encode(),
+						"total":       2,
+					},
+				})
+			}
+		})
+	}
+}
@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"go-crud/internal/auth"
+	"go-crud/internal/authz"
 	"go-crud/internal/domain"
+	"go-crud/internal/password"
+	"go-crud/internal/repository"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -13,6 +18,29 @@ import (
 	"time"
 )
 
+var (
+	testPasswords = password.NewService(password.NewBcryptHasher(4))
+	testPolicy    = authz.NewPolicy(authz.DefaultRules)
+)
+
+// passthroughTx runs units of work directly on the mock repository.
+type passthroughTx struct {
+	repos domain.Repositories
+}
+
+func (p passthroughTx) WithinTx(ctx context.Context, fn func(ctx context.Context, repos domain.Repositories) error) error {
+	return fn(ctx, p.repos)
+}
+
+func testTx(users domain.UserRepository) domain.TxManager {
+	return passthroughTx{repos: domain.Repositories{Users: users}}
+}
+
+func withPrincipal(req *http.Request, userID int64, role domain.Role) *http.Request {
+	principal := &auth.Principal{UserID: userID, Role: role}
+	return req.WithContext(auth.WithPrincipal(req.Context(), principal))
+}
+
 func checkResponseFields(t *testing.T, respBodyStr string, wantFields map[string]any) {
 	var got map[string]any
 	if err := json.Unmarshal([]byte(respBodyStr), &got); err != nil {
@@ -52,6 +80,16 @@ func TestUserHandler_Create(t *testing.T) {
 			wantStatus: http.StatusBadRequest,
 			wantBody:   `{"error":"invalid json"}`,
 		},
+		{
+			name:       "validation error",
+			body:       `{"username":"a b","email":"not-an-email","password":"short"}`,
+			repoErr:    nil,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody: `{"error":"validation failed","fields":[` +
+				`{"field":"email","message":"must be a valid email address"},` +
+				`{"field":"username","message":"may only contain letters, digits, '.', '_' and '-'"},` +
+				`{"field":"password","message":"must be at least 8 characters"}]}`,
+		},
 		{
 			name:       "repository error",
 			body:       `{"username":"testuser","email":"test@email.com","password":"password123"}`,
@@ -65,6 +103,9 @@ func TestUserHandler_Create(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			repo := &mockUserRepo{
 				createFunc: func(u *domain.User) error {
+					if _, err := testPasswords.Verify(u.Password, "password123"); err != nil {
+						t.Errorf("expected hashed password to be stored, got: %q", u.Password)
+					}
 					if test.repoErr == nil {
 						u.ID = 1
 						u.CreatedAt = time.Now()
@@ -73,8 +114,9 @@ func TestUserHandler_Create(t *testing.T) {
 					return test.repoErr
 				},
 			}
-			handler := NewUserHandler(repo)
+			handler := NewUserHandler(repo, testTx(repo), testPasswords, testPolicy)
 			req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(test.body))
+			req.Header.Set("Accept", "application/json")
 			w := httptest.NewRecorder()
 			handler.Create(w, req)
 			resp := w.Result()
@@ -156,8 +198,10 @@ func TestUserHandler_GetByID(t *testing.T) {
 					return test.repoUser, test.handlerErr
 				},
 			}
-			handler := NewUserHandler(repo)
+			handler := NewUserHandler(repo, testTx(repo), testPasswords, testPolicy)
 			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			req.Header.Set("Accept", "application/json")
+			req = withPrincipal(req, 1, domain.RoleAdmin)
 			w := httptest.NewRecorder()
 			handler.GetByID(w, req)
 			resp := w.Result()
@@ -188,49 +232,69 @@ func TestUserHandler_GetByID(t *testing.T) {
 func strPtr(s string) *string { return &s }
 
 func TestUserHandler_Update(t *testing.T) {
+	stored := &domain.User{ID: 1, Username: "olduser", Email: "old@email.com", Role: domain.RoleUser, Version: 3}
+
 	tests := []struct {
 		name       string
 		path       string
-		repoUser   *domain.User
-		handlerErr error
+		getErr     error
+		updateErr  error
 		body       string
 		wantStatus int
 		wantBody   string
 		wantUpdate *domain.UserUpdate
 	}{
 		{
-			name: "update email success",
-			path: "/users/1",
-			repoUser: &domain.User{
-				ID:       1,
-				Username: "olduser",
-				Email:    "new@email.com",
-			},
-			handlerErr: nil,
-			body:       `{"email":"new@email.com"}`,
+			name:       "update email success",
+			path:       "/users/1",
+			body:       `{"username":"olduser","email":"new@email.com","role":"user"}`,
+			wantStatus: http.StatusOK,
+			wantUpdate: &domain.UserUpdate{Email: strPtr("new@email.com"), Version: int64Ptr(3)},
+		},
+		{
+			name:       "read-only fields are ignored",
+			path:       "/users/1",
+			body:       `{"id":99,"username":"olduser","email":"new@email.com","role":"user","version":1,"created_at":"2023-01-01T12:00:00Z","updated_at":"2023-01-01T12:00:00Z"}`,
 			wantStatus: http.StatusOK,
-			wantBody:   `{"id":1,"email":"new@email.com","username":"olduser"}`,
-			wantUpdate: &domain.UserUpdate{Email: strPtr("new@email.com")},
+			wantUpdate: &domain.UserUpdate{Email: strPtr("new@email.com"), Version: int64Ptr(3)},
+		},
+		{
+			name:       "omitted field is cleared",
+			path:       "/users/1",
+			body:       `{"email":"new@email.com","role":"user"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `{"error":"validation failed","fields":[{"field":"username","message":"is required"}]}`,
 		},
 		{
 			name:       "unknown field in body",
 			path:       "/users/1",
-			repoUser:   nil,
-			handlerErr: ErrInvalidJSON,
 			body:       `{"mail":"new@email.com"}`,
 			wantStatus: http.StatusBadRequest,
 			wantBody:   fmt.Sprintf(`{"error":"%s"}`, ErrInvalidJSON.Message),
-			wantUpdate: nil,
+		},
+		{
+			name:       "invalid email",
+			path:       "/users/1",
+			body:       `{"username":"olduser","email":"not-an-email","role":"user"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantBody:   `{"error":"validation failed","fields":[{"field":"email","message":"must be a valid email address"}]}`,
 		},
 		{
 			name:       "user not found",
 			path:       "/users/9999",
-			repoUser:   nil,
-			handlerErr: domain.ErrNotFound,
-			body:       `{"email":"notfound@email.com"}`,
+			getErr:     domain.ErrNotFound,
+			body:       `{"username":"olduser","email":"notfound@email.com","role":"user"}`,
 			wantStatus: http.StatusNotFound,
 			wantBody:   `{"error":"resource not found"}`,
-			wantUpdate: &domain.UserUpdate{Email: strPtr("notfound@email.com")},
+		},
+		{
+			name:       "modified concurrently",
+			path:       "/users/1",
+			updateErr:  domain.ErrConflict,
+			body:       `{"username":"olduser","email":"new@email.com","role":"user"}`,
+			wantStatus: http.StatusConflict,
+			wantBody:   fmt.Sprintf(`{"error":"%s"}`, domain.ErrConflict),
+			wantUpdate: &domain.UserUpdate{Email: strPtr("new@email.com"), Version: int64Ptr(3)},
 		},
 	}
 
@@ -240,14 +304,19 @@ func TestUserHandler_Update(t *testing.T) {
 			repo := &mockUserRepo{
 				updateFunc: func(id int64, upd *domain.UserUpdate) error {
 					gotUpdate = upd
-					return test.handlerErr
+					return test.updateErr
 				},
 				getByIDFunc: func(id int64) (*domain.User, error) {
-					return test.repoUser, nil
+					if test.getErr != nil {
+						return nil, test.getErr
+					}
+					return stored, nil
 				},
 			}
-			handler := NewUserHandler(repo)
+			handler := NewUserHandler(repo, testTx(repo), testPasswords, testPolicy)
 			req := httptest.NewRequest(http.MethodPut, test.path, strings.NewReader(test.body))
+			req.Header.Set("Accept", "application/json")
+			req = withPrincipal(req, 1, domain.RoleAdmin)
 			w := httptest.NewRecorder()
 			handler.Update(w, req)
 			resp := w.Result()
@@ -260,10 +329,9 @@ func TestUserHandler_Update(t *testing.T) {
 				t.Fatalf("failed to read response body: %v", err)
 			}
 			respBodyStr := string(respBody)
-			if test.name == "update email success" {
+			if test.wantStatus == http.StatusOK {
 				checkResponseFields(t, respBodyStr, map[string]any{
 					"id":       1,
-					"email":    "new@email.com",
 					"username": "olduser",
 				})
 			} else {
@@ -271,10 +339,105 @@ func TestUserHandler_Update(t *testing.T) {
 					t.Errorf("expected response body: %s, got: %s", test.wantBody, respBodyStr)
 				}
 			}
-			if test.wantUpdate != nil && gotUpdate != nil {
-				if !reflect.DeepEqual(test.wantUpdate, gotUpdate) {
-					t.Errorf("expected update: %+v, got: %+v", test.wantUpdate, gotUpdate)
-				}
+			if !reflect.DeepEqual(test.wantUpdate, gotUpdate) {
+				t.Errorf("expected update: %+v, got: %+v", test.wantUpdate, gotUpdate)
+			}
+		})
+	}
+}
+
+func TestUserHandler_Patch(t *testing.T) {
+	stored := &domain.User{ID: 1, Username: "olduser", Email: "old@email.com", Role: domain.RoleUser, Version: 3}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantUpdate  *domain.UserUpdate
+	}{
+		{
+			name:        "merge patch",
+			contentType: "application/merge-patch+json",
+			body:        `{"email":"new@email.com"}`,
+			wantStatus:  http.StatusOK,
+			wantUpdate:  &domain.UserUpdate{Email: strPtr("new@email.com"), Version: int64Ptr(3)},
+		},
+		{
+			name:        "merge patch nulls required field",
+			contentType: "application/merge-patch+json",
+			body:        `{"username":null}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "merge patch sets password",
+			contentType: "application/merge-patch+json; charset=utf-8",
+			body:        `{"password":"newpassword1"}`,
+			wantStatus:  http.StatusOK,
+		},
+		{
+			name:        "merge patch adds unknown field",
+			contentType: "application/merge-patch+json",
+			body:        `{"id":5}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+		},
+		{
+			name:        "json patch test and replace",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"test","path":"/email","value":"old@email.com"},{"op":"replace","path":"/email","value":"new@email.com"}]`,
+			wantStatus:  http.StatusOK,
+			wantUpdate:  &domain.UserUpdate{Email: strPtr("new@email.com"), Version: int64Ptr(3)},
+		},
+		{
+			name:        "json patch test fails",
+			contentType: "application/json-patch+json",
+			body:        `[{"op":"test","path":"/email","value":"other@email.com"},{"op":"replace","path":"/email","value":"new@email.com"}]`,
+			wantStatus:  http.StatusConflict,
+		},
+		{
+			name:        "json patch malformed",
+			contentType: "application/json-patch+json",
+			body:        `{"op":"replace"}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "plain json",
+			contentType: "application/json",
+			body:        `{"email":"new@email.com"}`,
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotUpdate *domain.UserUpdate
+			repo := &mockUserRepo{
+				updateFunc: func(id int64, upd *domain.UserUpdate) error {
+					gotUpdate = upd
+					return nil
+				},
+				getByIDFunc: func(id int64) (*domain.User, error) {
+					return stored, nil
+				},
+			}
+			handler := NewUserHandler(repo, testTx(repo), testPasswords, testPolicy)
+			req := httptest.NewRequest(http.MethodPatch, "/users/1", strings.NewReader(test.body))
+			req.Header.Set("Content-Type", test.contentType)
+			req = withPrincipal(req, 1, domain.RoleAdmin)
+			w := httptest.NewRecorder()
+			handler.Patch(w, req)
+
+			if test.wantStatus != w.Code {
+				t.Errorf("expected status code: %v, got: %v (%s)", test.wantStatus, w.Code, w.Body)
+			}
+			if test.wantStatus == http.StatusUnsupportedMediaType && w.Header().Get("Accept-Patch") == "" {
+				t.Error("expected Accept-Patch header")
+			}
+			if test.wantUpdate != nil && !reflect.DeepEqual(test.wantUpdate, gotUpdate) {
+				t.Errorf("expected update: %+v, got: %+v", test.wantUpdate, gotUpdate)
+			}
+			if test.wantStatus != http.StatusOK && gotUpdate != nil {
+				t.Errorf("expected no update, got: %+v", gotUpdate)
 			}
 		})
 	}
@@ -325,8 +488,10 @@ func TestUserHandler_Delete(t *testing.T) {
 					return test.handlerErr
 				},
 			}
-			handler := NewUserHandler(repo)
+			handler := NewUserHandler(repo, testTx(repo), testPasswords, testPolicy)
 			req := httptest.NewRequest(http.MethodDelete, test.path, nil)
+			req.Header.Set("Accept", "application/json")
+			req = withPrincipal(req, 1, domain.RoleAdmin)
 			w := httptest.NewRecorder()
 			handler.Delete(w, req)
 			resp := w.Result()
@@ -352,9 +517,13 @@ type mockUserRepo struct {
 	getByIDFunc func(int64) (*domain.User, error)
 	updateFunc  func(int64, *domain.UserUpdate) error
 	deleteFunc  func(int64) error
+	restoreFunc func(int64) error
+	hardDelFunc func(int64) error
+	listFunc    func(domain.UserListParams) (*domain.UserList, error)
+	loginFunc   func(string) (*domain.User, error)
 }
 
-func (m *mockUserRepo) Create(u *domain.User) error {
+func (m *mockUserRepo) Create(ctx context.Context, u *domain.User) error {
 	if m.createFunc != nil {
 		return m.createFunc(u)
 	}
@@ -364,23 +533,338 @@ func (m *mockUserRepo) Create(u *domain.User) error {
 	return nil
 }
 
-func (m *mockUserRepo) GetByID(id int64) (*domain.User, error) {
+func (m *mockUserRepo) GetByID(ctx context.Context, id int64) (*domain.User, error) {
 	if m.getByIDFunc != nil {
 		return m.getByIDFunc(id)
 	}
 	return nil, nil
 }
 
-func (m *mockUserRepo) Update(id int64, upd *domain.UserUpdate) error {
+func (m *mockUserRepo) Update(ctx context.Context, id int64, upd *domain.UserUpdate) error {
 	if m.updateFunc != nil {
 		return m.updateFunc(id, upd)
 	}
 	return nil
 }
 
-func (m *mockUserRepo) Delete(id int64) error {
+func (m *mockUserRepo) Delete(ctx context.Context, id int64) error {
 	if m.deleteFunc != nil {
 		return m.deleteFunc(id)
 	}
 	return nil
 }
+
+func (m *mockUserRepo) Restore(ctx context.Context, id int64) error {
+	if m.restoreFunc != nil {
+		return m.restoreFunc(id)
+	}
+	return nil
+}
+
+func (m *mockUserRepo) HardDelete(ctx context.Context, id int64) error {
+	if m.hardDelFunc != nil {
+		return m.hardDelFunc(id)
+	}
+	return nil
+}
+
+func (m *mockUserRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockUserRepo) List(ctx context.Context, params domain.UserListParams) (*domain.UserList, error) {
+	if m.listFunc != nil {
+		return m.listFunc(params)
+	}
+	return &domain.UserList{}, nil
+}
+
+func (m *mockUserRepo) GetByLogin(ctx context.Context, login string) (*domain.User, error) {
+	if m.loginFunc != nil {
+		return m.loginFunc(login)
+	}
+	return nil, domain.ErrNotFound
+}
+
+func TestUserHandler_Authorization(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		principal  *auth.Principal
+		wantStatus int
+	}{
+		{
+			name:       "anonymous read",
+			method:     http.MethodGet,
+			path:       "/users/1",
+			principal:  nil,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "user reads self",
+			method:     http.MethodGet,
+			path:       "/users/1",
+			principal:  &auth.Principal{UserID: 1, Role: domain.RoleUser},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "user reads other",
+			method:     http.MethodGet,
+			path:       "/users/2",
+			principal:  &auth.Principal{UserID: 1, Role: domain.RoleUser},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "user deletes other",
+			method:     http.MethodDelete,
+			path:       "/users/2",
+			principal:  &auth.Principal{UserID: 1, Role: domain.RoleUser},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "user hard-deletes self",
+			method:     http.MethodDelete,
+			path:       "/users/1?hard=true",
+			principal:  &auth.Principal{UserID: 1, Role: domain.RoleUser},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "admin hard-deletes other",
+			method:     http.MethodDelete,
+			path:       "/users/2?hard=true",
+			principal:  &auth.Principal{UserID: 1, Role: domain.RoleAdmin},
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "user restores self",
+			method:     http.MethodPost,
+			path:       "/users/1/restore",
+			principal:  &auth.Principal{UserID: 1, Role: domain.RoleUser},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "admin restores other",
+			method:     http.MethodPost,
+			path:       "/users/2/restore",
+			principal:  &auth.Principal{UserID: 1, Role: domain.RoleAdmin},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "restore invalid id",
+			method:     http.MethodPost,
+			path:       "/users/abc/restore",
+			principal:  &auth.Principal{UserID: 1, Role: domain.RoleAdmin},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "user promotes self",
+			method:     http.MethodPut,
+			path:       "/users/1",
+			body:       `{"username":"self","email":"self@email.com","role":"admin"}`,
+			principal:  &auth.Principal{UserID: 1, Role: domain.RoleUser},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "admin promotes other",
+			method:     http.MethodPut,
+			path:       "/users/2",
+			body:       `{"username":"other","email":"other@email.com","role":"admin"}`,
+			principal:  &auth.Principal{UserID: 1, Role: domain.RoleAdmin},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "user lists users",
+			method:     http.MethodGet,
+			path:       "/users",
+			principal:  &auth.Principal{UserID: 1, Role: domain.RoleUser},
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo := &mockUserRepo{
+				getByIDFunc: func(id int64) (*domain.User, error) {
+					return &domain.User{ID: id, Role: domain.RoleUser}, nil
+				},
+			}
+			handler := NewUserHandler(repo, testTx(repo), testPasswords, testPolicy)
+			mux := http.NewServeMux()
+			mux.HandleFunc("/users", MethodRouter(MethodHandlers{http.MethodGet: handler.List}))
+			mux.HandleFunc("/users/{id}", MethodRouter(MethodHandlers{
+				http.MethodGet:    handler.GetByID,
+				http.MethodPut:    handler.Update,
+				http.MethodDelete: handler.Delete,
+			}))
+			mux.HandleFunc("/users/{id}/restore", MethodRouter(MethodHandlers{http.MethodPost: handler.Restore}))
+
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			if test.principal != nil {
+				req = withPrincipal(req, test.principal.UserID, test.principal.Role)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if test.wantStatus != w.Code {
+				t.Errorf("expected status code: %v, got: %v (%s)", test.wantStatus, w.Code, w.Body)
+			}
+		})
+	}
+}
+
+func TestUserHandler_ConditionalRequests(t *testing.T) {
+	current := &domain.User{ID: 1, Username: "testuser", Email: "test@email.com", Role: domain.RoleUser, Version: 3}
+
+	tests := []struct {
+		name        string
+		method      string
+		header      string
+		value       string
+		updateErr   error
+		wantStatus  int
+		wantVersion *int64
+	}{
+		{name: "get sets etag", method: http.MethodGet, wantStatus: http.StatusOK},
+		{name: "get not modified", method: http.MethodGet, header: "If-None-Match", value: `"3"`, wantStatus: http.StatusNotModified},
+		{name: "get weak match", method: http.MethodGet, header: "If-None-Match", value: `W/"3"`, wantStatus: http.StatusNotModified},
+		{name: "get modified", method: http.MethodGet, header: "If-None-Match", value: `"2"`, wantStatus: http.StatusOK},
+		{name: "put unconditional", method: http.MethodPut, wantStatus: http.StatusOK, wantVersion: int64Ptr(3)},
+		{name: "put matching", method: http.MethodPut, header: "If-Match", value: `"3"`, wantStatus: http.StatusOK, wantVersion: int64Ptr(3)},
+		{name: "put any", method: http.MethodPut, header: "If-Match", value: "*", wantStatus: http.StatusOK, wantVersion: int64Ptr(3)},
+		{name: "put one of several", method: http.MethodPut, header: "If-Match", value: `"1", "3"`, wantStatus: http.StatusOK, wantVersion: int64Ptr(3)},
+		{name: "put none of several", method: http.MethodPut, header: "If-Match", value: `"1", "2"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "put weak tag", method: http.MethodPut, header: "If-Match", value: `W/"3"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "put stale", method: http.MethodPut, header: "If-Match", value: `"2"`, wantStatus: http.StatusPreconditionFailed},
+		{name: "put modified meanwhile", method: http.MethodPut, header: "If-Match", value: `"3"`, updateErr: domain.ErrConflict, wantStatus: http.StatusPreconditionFailed, wantVersion: int64Ptr(3)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gotUpdate *domain.UserUpdate
+			repo := &mockUserRepo{
+				getByIDFunc: func(id int64) (*domain.User, error) {
+					return current, nil
+				},
+				updateFunc: func(id int64, upd *domain.UserUpdate) error {
+					gotUpdate = upd
+					return test.updateErr
+				},
+			}
+			handler := NewUserHandler(repo, testTx(repo), testPasswords, testPolicy)
+
+			req := httptest.NewRequest(test.method, "/users/1", strings.NewReader(`{"username":"renamed","email":"test@email.com","role":"user"}`))
+			if test.header != "" {
+				req.Header.Set(test.header, test.value)
+			}
+			req = withPrincipal(req, 1, domain.RoleAdmin)
+			w := httptest.NewRecorder()
+			if test.method == http.MethodGet {
+				handler.GetByID(w, req)
+			} else {
+				handler.Update(w, req)
+			}
+
+			if w.Code != test.wantStatus {
+				t.Errorf("expected status code: %v, got: %v", test.wantStatus, w.Code)
+			}
+			if w.Code < http.StatusBadRequest {
+				if got := w.Header().Get("ETag"); got != `"3"` {
+					t.Errorf("expected etag: %v, got: %v", `"3"`, got)
+				}
+			}
+			if test.wantVersion != nil {
+				if gotUpdate == nil || gotUpdate.Version == nil || *gotUpdate.Version != *test.wantVersion {
+					t.Errorf("expected update of version %d, got: %+v", *test.wantVersion, gotUpdate)
+				}
+			} else if gotUpdate != nil {
+				t.Errorf("expected no update, got: %+v", gotUpdate)
+			}
+		})
+	}
+}
+
+func int64Ptr(n int64) *int64 { return &n }
+
+func TestUserHandler_MemoryRepository(t *testing.T) {
+	store := repository.NewMemoryStore()
+	handler := NewUserHandler(store.Repositories().Users, store, testPasswords, testPolicy)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", MethodRouter(MethodHandlers{http.MethodPost: handler.Create}))
+	mux.HandleFunc("/users/{id}", MethodRouter(MethodHandlers{
+		http.MethodGet:    handler.GetByID,
+		http.MethodDelete: handler.Delete,
+	}))
+	mux.HandleFunc("/users/{id}/restore", MethodRouter(MethodHandlers{http.MethodPost: handler.Restore}))
+
+	body := `{"username":"testuser","email":"test@email.com","password":"password123"}`
+	steps := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		principal  *auth.Principal
+		wantStatus int
+	}{
+		{name: "create", method: http.MethodPost, path: "/users", body: body, wantStatus: http.StatusCreated},
+		{name: "create duplicate", method: http.MethodPost, path: "/users", body: body, wantStatus: http.StatusConflict},
+		{name: "get", method: http.MethodGet, path: "/users/1", principal: &auth.Principal{UserID: 1, Role: domain.RoleUser}, wantStatus: http.StatusOK},
+		{name: "get missing", method: http.MethodGet, path: "/users/2", principal: &auth.Principal{UserID: 2, Role: domain.RoleUser}, wantStatus: http.StatusNotFound},
+		{name: "delete", method: http.MethodDelete, path: "/users/1", principal: &auth.Principal{UserID: 1, Role: domain.RoleUser}, wantStatus: http.StatusNoContent},
+		{name: "get deleted", method: http.MethodGet, path: "/users/1", principal: &auth.Principal{UserID: 1, Role: domain.RoleUser}, wantStatus: http.StatusNotFound},
+		{name: "restore", method: http.MethodPost, path: "/users/1/restore", principal: &auth.Principal{UserID: 2, Role: domain.RoleAdmin}, wantStatus: http.StatusOK},
+		{name: "get restored", method: http.MethodGet, path: "/users/1", principal: &auth.Principal{UserID: 1, Role: domain.RoleUser}, wantStatus: http.StatusOK},
+	}
+
+	// The steps share the repository, so they run in order and stop at
+	// the first failure.
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.path, strings.NewReader(step.body))
+		req.Header.Set("Accept", "application/json")
+		if step.principal != nil {
+			req = withPrincipal(req, step.principal.UserID, step.principal.Role)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if step.wantStatus != w.Code {
+			t.Fatalf("%s: expected status code: %v, got: %v (%s)", step.name, step.wantStatus, w.Code, w.Body)
+		}
+	}
+}
+
+// TestUserHandler_GetThenPut sends a fetched user back through PUT, the
+// way a client edits a user.
+func TestUserHandler_GetThenPut(t *testing.T) {
+	store := repository.NewMemoryStore()
+	users := store.Repositories().Users
+	if err := users.Create(context.Background(), &domain.User{Username: "testuser", Email: "test@email.com", Password: "hash"}); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+
+	handler := NewUserHandler(users, store, testPasswords, testPolicy)
+
+	req := withPrincipal(httptest.NewRequest(http.MethodGet, "/users/1", nil), 1, domain.RoleUser)
+	w := httptest.NewRecorder()
+	handler.GetByID(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code: %v, got: %v (%s)", http.StatusOK, w.Code, w.Body)
+	}
+
+	body := strings.Replace(w.Body.String(), "test@email.com", "new@email.com", 1)
+	req = withPrincipal(httptest.NewRequest(http.MethodPut, "/users/1", strings.NewReader(body)), 1, domain.RoleUser)
+	w = httptest.NewRecorder()
+	handler.Update(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code: %v, got: %v (%s)", http.StatusOK, w.Code, w.Body)
+	}
+
+	user, err := users.GetByID(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.Email != "new@email.com" || user.Version != 2 {
+		t.Errorf("expected the email to be replaced, got: %+v", user)
+	}
+}
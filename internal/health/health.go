@@ -0,0 +1,161 @@
+// Package health reports whether the service and its dependencies can
+// serve requests
+package health
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker checks one dependency, returning nil when it is usable.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Pinger is implemented by *sql.DB.
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Ping checks that a connection to the database can be established.
+func Ping(db Pinger) Checker {
+	return CheckerFunc(db.PingContext)
+}
+
+// CheckResult is the outcome of one checker. Error is safe to show to
+// anyone; Err holds the detail, which may name hosts or echo server
+// messages, and is meant for the logs.
+type CheckResult struct {
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+	Err     error  `json:"-"`
+}
+
+// Report is the outcome of running every registered checker.
+type Report struct {
+	Status   string                 `json:"status"`
+	Draining bool                   `json:"draining,omitempty"`
+	Checks   map[string]CheckResult `json:"checks"`
+}
+
+func (r *Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Registry holds the checkers that decide whether the service is ready.
+// Dependencies register themselves when they are set up.
+type Registry struct {
+	timeout  time.Duration
+	draining atomic.Bool
+
+	mu       sync.RWMutex
+	names    []string
+	checkers map[string]Checker
+}
+
+// NewRegistry returns an empty registry. Each checker gets at most timeout
+// to report; zero disables the deadline.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout, checkers: map[string]Checker{}}
+}
+
+// Register adds c under name, replacing any checker of the same name.
+func (r *Registry) Register(name string, c Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.checkers[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.checkers[name] = c
+}
+
+// Drain marks the service as shutting down, which fails every report from
+// then on so load balancers stop sending traffic.
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+func (r *Registry) Draining() bool {
+	return r.draining.Load()
+}
+
+// Check runs every checker concurrently and reports their results.
+func (r *Registry) Check(ctx context.Context) *Report {
+	r.mu.RLock()
+	names := slices.Clone(r.names)
+	checkers := make([]Checker, len(names))
+	for i, name := range names {
+		checkers[i] = r.checkers[name]
+	}
+	r.mu.RUnlock()
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i, checker := range checkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.run(ctx, checker)
+		}()
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusOK, Draining: r.Draining(), Checks: make(map[string]CheckResult, len(names))}
+	if report.Draining {
+		report.Status = StatusFail
+	}
+	for i, name := range names {
+		report.Checks[name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, c Checker) CheckResult {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	// A checker that ignores ctx must not hold up the whole report.
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: StatusOK, Latency: time.Since(start).String()}
+	switch {
+	case err == nil:
+	case errors.Is(err, context.DeadlineExceeded):
+		result.Status, result.Error, result.Err = StatusFail, "timed out", err
+	default:
+		result.Status, result.Error, result.Err = StatusFail, "check failed", err
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegistry_Check(t *testing.T) {
+	ok := CheckerFunc(func(ctx context.Context) error { return nil })
+	failing := CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") })
+	// hanging ignores its context, the registry must give up on it anyway.
+	hanging := CheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	subtests := []struct {
+		name       string
+		checkers   map[string]Checker
+		draining   bool
+		wantStatus string
+		wantChecks map[string]string
+	}{
+		{
+			name:       "no checkers",
+			wantStatus: StatusOK,
+			wantChecks: map[string]string{},
+		},
+		{
+			name:       "all passing",
+			checkers:   map[string]Checker{"database": ok, "cache": ok},
+			wantStatus: StatusOK,
+			wantChecks: map[string]string{"database": StatusOK, "cache": StatusOK},
+		},
+		{
+			name:       "one failing",
+			checkers:   map[string]Checker{"database": failing, "cache": ok},
+			wantStatus: StatusFail,
+			wantChecks: map[string]string{"database": StatusFail, "cache": StatusOK},
+		},
+		{
+			name:       "timeout",
+			checkers:   map[string]Checker{"database": hanging},
+			wantStatus: StatusFail,
+			wantChecks: map[string]string{"database": StatusFail},
+		},
+		{
+			name:       "draining",
+			checkers:   map[string]Checker{"database": ok},
+			draining:   true,
+			wantStatus: StatusFail,
+			wantChecks: map[string]string{"database": StatusOK},
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			registry := NewRegistry(10 * time.Millisecond)
+			for name, checker := range subtest.checkers {
+				registry.Register(name, checker)
+			}
+			if subtest.draining {
+				registry.Drain()
+			}
+
+			report := registry.Check(context.Background())
+			if report.Status != subtest.wantStatus {
+				t.Errorf("expected status: %q, got: %q", subtest.wantStatus, report.Status)
+			}
+			if report.Draining != subtest.draining {
+				t.Errorf("expected draining: %v, got: %v", subtest.draining, report.Draining)
+			}
+			if len(report.Checks) != len(subtest.wantChecks) {
+				t.Errorf("expected %d checks, got: %v", len(subtest.wantChecks), report.Checks)
+			}
+			for name, want := range subtest.wantChecks {
+				got := report.Checks[name]
+				if got.Status != want {
+					t.Errorf("%s: expected status: %q, got: %q", name, want, got.Status)
+				}
+				if (got.Error != "") != (want == StatusFail) || (got.Err != nil) != (want == StatusFail) {
+					t.Errorf("%s: unexpected error: %q, %v", name, got.Error, got.Err)
+				}
+				// The details stay out of the public message.
+				if strings.Contains(got.Error, "connection refused") {
+					t.Errorf("%s: expected a generic error message, got: %q", name, got.Error)
+				}
+			}
+		})
+	}
+}
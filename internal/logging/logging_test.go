@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-crud/internal/config"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew_ContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(config.LoggingConfig{Level: "info", Format: "json"}, &buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := WithAttrs(context.Background(), slog.String("request_id", "abc"))
+	ctx = WithAttrs(ctx, slog.Int64("user_id", 7))
+	logger.InfoContext(ctx, "hello", "route", "/users/{id}")
+	logger.DebugContext(ctx, "dropped")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected a single record above debug level, got: %q", lines)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("expected JSON output: %v", err)
+	}
+	for key, want := range map[string]any{"msg": "hello", "request_id": "abc", "user_id": float64(7), "route": "/users/{id}"} {
+		if record[key] != want {
+			t.Errorf("field %s: expected %v, got %v", key, want, record[key])
+		}
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	subtests := []struct {
+		name string
+		cfg  config.LoggingConfig
+	}{
+		{name: "level", cfg: config.LoggingConfig{Level: "loud", Format: "json"}},
+		{name: "format", cfg: config.LoggingConfig{Level: "info", Format: "xml"}},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			if _, err := New(subtest.cfg, &bytes.Buffer{}); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	m := New()
+	m.RegisterDB(db, "mysql")
+	m.ObserveRequest("GET /users/{id}", http.MethodGet, http.StatusOK, 20*time.Millisecond)
+	m.ObserveRequest("GET /users/{id}", http.MethodGet, http.StatusOK, 30*time.Millisecond)
+	m.ObserveRequest("GET /users/{id}", http.MethodGet, http.StatusNotFound, time.Millisecond)
+	m.ObserveQuery("get_by_id", 2*time.Millisecond, nil)
+	m.ObserveQuery("get_by_id", time.Millisecond, errors.New("boom"))
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`http_requests_total{method="GET",route="GET /users/{id}",status="200"} 2`,
+		`http_requests_total{method="GET",route="GET /users/{id}",status="404"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="GET /users/{id}",status="200"} 2`,
+		`db_query_duration_seconds_count{operation="get_by_id",result="success"} 1`,
+		`db_query_duration_seconds_count{operation="get_by_id",result="error"} 1`,
+		`go_sql_open_connections{db_name="mysql"}`,
+		`go_sql_max_open_connections{db_name="mysql"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics to contain %q", want)
+		}
+	}
+}
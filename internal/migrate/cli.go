@@ -0,0 +1,192 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+const usage = `usage: migrate <command> [arguments]
+
+commands:
+  up [N]         apply all or N pending migrations
+  down N|-all    roll back N migrations, or all of them with -all
+  goto V         migrate up or down to version V
+  status         print the current version and dirty flag
+  force V        set the version to V without running migrations,
+                 after fixing a failed migration by hand
+  create NAME    create timestamped up/down SQL files in the migrations
+                 directory; rebuild the binary to embed them`
+
+// CLI implements the "migrate" subcommand of the service binary.
+type CLI struct {
+	// Connect opens the database, which Run closes when done; it is not
+	// called for "create".
+	Connect func() (*sql.DB, error)
+	// Driver selects the migration set, see config.DatabaseConfig.
+	Driver string
+	// Dir is an external migrations directory; when empty the embedded
+	// migrations are used and "create" writes to the driver's directory
+	// under DefaultDir.
+	Dir string
+	Out io.Writer
+	Now func() time.Time
+}
+
+func (c *CLI) Run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	cmd, args := args[0], args[1:]
+	if cmd == "create" {
+		if len(args) != 1 {
+			return errors.New("usage: migrate create NAME")
+		}
+		return c.create(args[0])
+	}
+
+	run, ok := map[string]func(*migrate.Migrate, []string) error{
+		"up":     c.up,
+		"down":   c.down,
+		"goto":   c.gotoVersion,
+		"status": c.status,
+		"force":  c.force,
+	}[cmd]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", cmd, usage)
+	}
+
+	db, err := c.Connect()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	m, err := New(context.Background(), db, c.Driver, c.Dir)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := run(m, args); err != nil {
+		return err
+	}
+	return c.status(m, nil)
+}
+
+func (c *CLI) up(m *migrate.Migrate, args []string) error {
+	if len(args) == 0 {
+		return ignoreNoChange(m.Up())
+	}
+	n, err := parseSteps(args)
+	if err != nil {
+		return err
+	}
+	return ignoreNoChange(m.Steps(n))
+}
+
+func (c *CLI) down(m *migrate.Migrate, args []string) error {
+	if len(args) == 1 && args[0] == "-all" {
+		return ignoreNoChange(m.Down())
+	}
+	n, err := parseSteps(args)
+	if err != nil {
+		return errors.New("usage: migrate down N|-all")
+	}
+	return ignoreNoChange(m.Steps(-n))
+}
+
+func (c *CLI) gotoVersion(m *migrate.Migrate, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate goto V")
+	}
+	v, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid version %q", args[0])
+	}
+	return ignoreNoChange(m.Migrate(uint(v)))
+}
+
+func (c *CLI) force(m *migrate.Migrate, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate force V")
+	}
+	v, err := strconv.Atoi(args[0])
+	if err != nil || v < -1 {
+		return fmt.Errorf("invalid version %q", args[0])
+	}
+	return m.Force(v)
+}
+
+func (c *CLI) status(m *migrate.Migrate, _ []string) error {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		fmt.Fprintln(c.Out, "no migrations applied")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read version: %w", err)
+	}
+	fmt.Fprintf(c.Out, "version: %d, dirty: %t\n", version, dirty)
+	return nil
+}
+
+var nonIdentifierChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+func (c *CLI) create(name string) error {
+	name = strings.Trim(nonIdentifierChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return errors.New("migration name must contain letters or digits")
+	}
+
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	dir := c.Dir
+	if dir == "" {
+		dir = driverDir(c.Driver)
+	}
+	base := filepath.Join(dir, now().UTC().Format("20060102150405")+"_"+name)
+
+	for _, direction := range []string{"up", "down"} {
+		path := base + "." + direction + ".sql"
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.Out, "created", path)
+	}
+	return nil
+}
+
+func parseSteps(args []string) (int, error) {
+	if len(args) != 1 {
+		return 0, errors.New("expected a single step count")
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid step count %q", args[0])
+	}
+	return n, nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package migrate
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestCLI_Create(t *testing.T) {
+	dir := t.TempDir()
+	cli := &CLI{
+		Connect: func() (*sql.DB, error) {
+			t.Fatal("create must not connect to the database")
+			return nil, nil
+		},
+		Dir: dir,
+		Out: &bytes.Buffer{},
+		Now: func() time.Time { return time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC) },
+	}
+
+	if err := cli.Run([]string{"create", "Add roles-table"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	sort.Strings(got)
+
+	want := []string{
+		"20240506070809_add_roles_table.down.sql",
+		"20240506070809_add_roles_table.up.sql",
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected files: %v, got: %v", want, got)
+	}
+
+	if err := cli.Run([]string{"create", "add roles table"}); err == nil {
+		t.Error("expected error when migration files already exist")
+	}
+	if _, err := os.Stat(filepath.Join(dir, want[1])); err != nil {
+		t.Errorf("expected existing file to be kept: %v", err)
+	}
+}
+
+func TestCLI_InvalidArguments(t *testing.T) {
+	connectErr := errors.New("connect called")
+	cli := &CLI{
+		Connect: func() (*sql.DB, error) { return nil, connectErr },
+		Dir:     t.TempDir(),
+		Out:     &bytes.Buffer{},
+	}
+
+	subtests := []struct {
+		name string
+		args []string
+	}{
+		{name: "no command", args: nil},
+		{name: "unknown command", args: []string{"sideways"}},
+		{name: "create without name", args: []string{"create"}},
+		{name: "create with unusable name", args: []string{"create", "---"}},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			err := cli.Run(subtest.args)
+			if err == nil || errors.Is(err, connectErr) {
+				t.Errorf("expected argument error, got: %v", err)
+			}
+		})
+	}
+}
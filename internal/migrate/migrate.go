@@ -1,39 +1,148 @@
 package migrate
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"go-crud/db/migrations"
+	"go-crud/internal/config"
+	"log/slog"
+	"path"
 
-	_ "github.com/go-sql-driver/mysql"
-	"github.com/golang-migrate/migrate"
-	"github.com/golang-migrate/migrate/database/mysql"
-	_ "github.com/golang-migrate/migrate/source/file"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/mysql"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
-func ApplyMigrations(db *sql.DB) error {
-	driver, err := mysql.WithInstance(db, &mysql.Config{})
+// DefaultDir is where "migrate create" scaffolds new migrations and the
+// directory embedded into the binary. It holds one subdirectory per
+// database driver.
+const DefaultDir = "db/migrations"
+
+// New returns a migrate instance bound to a connection taken from db.
+// Migrations are read from the driver's copy embedded in the binary unless
+// dir points at an external directory. Closing the instance releases the
+// connection and the migration source; db stays open for the caller.
+func New(ctx context.Context, db *sql.DB, driver, dir string) (*migrate.Migrate, error) {
+	instance, err := databaseInstance(ctx, db, driver)
 	if err != nil {
-		return fmt.Errorf("failed to create MySQL driver instance: %w", err)
+		return nil, err
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://db/migrations",
-		"mysql",
-		driver,
-	)
+	if dir != "" {
+		m, err := migrate.NewWithDatabaseInstance("file://"+dir, driver, instance)
+		if err != nil {
+			instance.Close()
+			return nil, fmt.Errorf("migration init error: %w", err)
+		}
+		return m, nil
+	}
+
+	source, err := iofs.New(migrations.FS, driver)
 	if err != nil {
-		return fmt.Errorf("migration init error: %w", err)
+		instance.Close()
+		return nil, fmt.Errorf("failed to read embedded migrations: %w", err)
 	}
 
+	m, err := migrate.NewWithInstance("iofs", source, driver, instance)
+	if err != nil {
+		instance.Close()
+		source.Close()
+		return nil, fmt.Errorf("migration init error: %w", err)
+	}
+	return m, nil
+}
+
+// databaseInstance returns the migrate driver for db. The MySQL and
+// PostgreSQL drivers hold one connection for their lock, so they are given
+// a dedicated one that Close returns to the pool.
+func databaseInstance(ctx context.Context, db *sql.DB, driver string) (database.Driver, error) {
+	if driver == config.DriverSQLite {
+		instance, err := sqlite.WithInstance(db, &sqlite.Config{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s driver instance: %w", driver, err)
+		}
+		return sharedDB{instance}, nil
+	}
+	if driver != config.DriverMySQL && driver != config.DriverPostgres {
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get a connection: %w", err)
+	}
+	var instance database.Driver
+	if driver == config.DriverMySQL {
+		instance, err = mysql.WithConnection(ctx, conn, &mysql.Config{})
+	} else {
+		// The pgx driver has no WithConnection; the lib/pq based one
+		// only uses the connection through database/sql.
+		instance, err = postgres.WithConnection(ctx, conn, &postgres.Config{})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create %s driver instance: %w", driver, err)
+	}
+	return instance, nil
+}
+
+// sharedDB keeps Close from closing the pool of a driver that works on it
+// directly.
+type sharedDB struct {
+	database.Driver
+}
+
+func (sharedDB) Close() error {
+	return nil
+}
+
+// driverDir is the directory "migrate create" writes to when no external
+// directory is configured.
+func driverDir(driver string) string {
+	return path.Join(DefaultDir, driver)
+}
+
+func ApplyMigrations(ctx context.Context, db *sql.DB, driver, dir string, logger *slog.Logger) error {
+	m, err := New(ctx, db, driver, dir)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
 	err = m.Up()
 	switch err {
 	case nil:
-		log.Println("migrations applied successfully")
+		logger.Info("migrations applied successfully")
 	case migrate.ErrNoChange:
-		log.Println("no migrations to apply")
+		logger.Info("no migrations to apply")
 	default:
 		return fmt.Errorf("migration up error: %w", err)
 	}
 	return nil
 }
+
+// CheckSchema reports an error when the schema of db has not been
+// migrated or a migration failed halfway and left it dirty. It reads the
+// version table directly rather than taking a connection for New.
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	var (
+		version int64
+		dirty   bool
+	)
+	err := db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return errors.New("no migrations applied")
+	case err != nil:
+		return fmt.Errorf("failed to read migration version: %w", err)
+	case dirty:
+		return fmt.Errorf("migration %d failed, schema is dirty", version)
+	}
+	return nil
+}
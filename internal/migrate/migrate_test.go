@@ -0,0 +1,120 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"go-crud/db/migrations"
+	"go-crud/internal/config"
+	"go-crud/internal/logging"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	_ "modernc.org/sqlite"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	for _, driver := range []string{config.DriverMySQL, config.DriverPostgres, config.DriverSQLite} {
+		t.Run(driver, func(t *testing.T) {
+			checkMigrationPairs(t, driver)
+		})
+	}
+}
+
+// checkMigrationPairs verifies that every embedded migration of driver has
+// both an up and a down file.
+func checkMigrationPairs(t *testing.T, driver string) {
+	t.Helper()
+	source, err := iofs.New(migrations.FS, driver)
+	if err != nil {
+		t.Fatalf("failed to open embedded migrations: %v", err)
+	}
+	defer source.Close()
+
+	version, err := source.First()
+	if err != nil {
+		t.Fatalf("expected at least one embedded migration: %v", err)
+	}
+
+	for {
+		up, _, err := source.ReadUp(version)
+		if err != nil {
+			t.Fatalf("missing up migration for version %d: %v", version, err)
+		}
+		up.Close()
+
+		down, _, err := source.ReadDown(version)
+		if err != nil {
+			t.Fatalf("missing down migration for version %d: %v", version, err)
+		}
+		down.Close()
+
+		next, err := source.Next(version)
+		if err != nil {
+			break
+		}
+		version = next
+	}
+}
+
+// TestApplyMigrations_SQLite runs the SQLite migrations up and down, the
+// only set that can be exercised without a database server.
+func TestApplyMigrations_SQLite(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := ApplyMigrations(ctx, db, config.DriverSQLite, "", logging.Discard()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if inUse := db.Stats().InUse; inUse != 0 {
+		t.Errorf("expected no connections in use, got: %d", inUse)
+	}
+
+	m, err := New(ctx, db, config.DriverSQLite, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := m.Down(); err != nil {
+		t.Fatalf("failed to roll back migrations: %v", err)
+	}
+	if sourceErr, dbErr := m.Close(); sourceErr != nil || dbErr != nil {
+		t.Fatalf("failed to close migrate: %v, %v", sourceErr, dbErr)
+	}
+
+	// The pool outlives the migrate instance.
+	if err := db.PingContext(ctx); err != nil {
+		t.Errorf("expected the database to stay open, got: %v", err)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := CheckSchema(ctx, db); err == nil {
+		t.Errorf("expected an error before migrating")
+	}
+
+	if err := ApplyMigrations(ctx, db, config.DriverSQLite, "", logging.Discard()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := CheckSchema(ctx, db); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if _, err := db.Exec("UPDATE schema_migrations SET dirty = 1"); err != nil {
+		t.Fatalf("failed to mark schema dirty: %v", err)
+	}
+	if err := CheckSchema(ctx, db); err == nil || !strings.Contains(err.Error(), "dirty") {
+		t.Errorf("expected a dirty schema error, got: %v", err)
+	}
+}
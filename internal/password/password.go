@@ -0,0 +1,110 @@
+// Package password hashes and verifies user passwords
+package password
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"go-crud/internal/config"
+	"strings"
+)
+
+var (
+	ErrMismatch    = errors.New("password does not match")
+	ErrUnknownHash = errors.New("unrecognised password hash format")
+	ErrTooLong     = errors.New("password is too long")
+)
+
+// Hasher is implemented by every supported hashing algorithm.
+type Hasher interface {
+	Hash(password string) (string, error)
+	Compare(hash, password string) error
+	// Supports reports whether hash was produced by this algorithm.
+	Supports(hash string) bool
+	// NeedsRehash reports whether hash was produced with parameters
+	// other than the ones the hasher is currently configured with.
+	NeedsRehash(hash string) bool
+}
+
+// Service hashes new passwords with its primary hasher and verifies
+// stored hashes produced by any of the known hashers.
+type Service struct {
+	primary Hasher
+	hashers []Hasher
+}
+
+func NewService(primary Hasher, legacy ...Hasher) *Service {
+	return &Service{
+		primary: primary,
+		hashers: append([]Hasher{primary}, legacy...),
+	}
+}
+
+func NewServiceFromConfig(cfg config.PasswordConfig) (*Service, error) {
+	bcryptHasher := NewBcryptHasher(cfg.BcryptCost)
+	argon2Hasher := NewArgon2idHasher(Argon2idParams{
+		Time:    cfg.Argon2Time,
+		Memory:  cfg.Argon2Memory,
+		Threads: cfg.Argon2Threads,
+	})
+
+	switch cfg.Algorithm {
+	case "bcrypt":
+		return NewService(bcryptHasher, argon2Hasher), nil
+	case "argon2id":
+		return NewService(argon2Hasher, bcryptHasher), nil
+	default:
+		return nil, fmt.Errorf("unsupported password algorithm %q", cfg.Algorithm)
+	}
+}
+
+func (s *Service) Hash(password string) (string, error) {
+	return s.primary.Hash(password)
+}
+
+// Verify checks password against hash. When the hash was produced by a
+// different algorithm or with outdated parameters, a fresh hash made with
+// the primary hasher is returned so the caller can persist it; otherwise
+// the returned string is empty.
+//
+// Passwords stored before hashing was introduced are plaintext; they are
+// verified the same way and always rehashed.
+func (s *Service) Verify(hash, password string) (string, error) {
+	hasher := s.hasherFor(hash)
+	switch {
+	case hasher != nil:
+		if err := hasher.Compare(hash, password); err != nil {
+			return "", err
+		}
+		if hasher == s.primary && !s.primary.NeedsRehash(hash) {
+			return "", nil
+		}
+	case isLegacyPlaintext(hash):
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(password)) != 1 {
+			return "", ErrMismatch
+		}
+	default:
+		return "", ErrUnknownHash
+	}
+
+	rehashed, err := s.primary.Hash(password)
+	if err != nil {
+		return "", fmt.Errorf("failed to rehash password: %w", err)
+	}
+	return rehashed, nil
+}
+
+// isLegacyPlaintext reports whether hash is a plaintext password rather
+// than the output of a hashing algorithm, all of which start with "$".
+func isLegacyPlaintext(hash string) bool {
+	return hash != "" && !strings.HasPrefix(hash, "$")
+}
+
+func (s *Service) hasherFor(hash string) Hasher {
+	for _, h := range s.hashers {
+		if h.Supports(hash) {
+			return h
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestService_HashAndVerify(t *testing.T) {
+	subtests := []struct {
+		name   string
+		hasher Hasher
+	}{
+		{name: "bcrypt", hasher: NewBcryptHasher(4)},
+		{name: "argon2id", hasher: NewArgon2idHasher(Argon2idParams{Time: 1, Memory: 1024, Threads: 1})},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			svc := NewService(subtest.hasher)
+
+			hash, err := svc.Hash("password123")
+			if err != nil {
+				t.Fatalf("unexpected hash error: %v", err)
+			}
+			if hash == "password123" {
+				t.Fatal("expected password to be hashed")
+			}
+
+			rehashed, err := svc.Verify(hash, "password123")
+			if err != nil {
+				t.Errorf("expected password to verify, got: %v", err)
+			}
+			if rehashed != "" {
+				t.Errorf("expected no rehash, got: %q", rehashed)
+			}
+
+			if _, err := svc.Verify(hash, "wrong"); !errors.Is(err, ErrMismatch) {
+				t.Errorf("expected error: %v, got: %v", ErrMismatch, err)
+			}
+		})
+	}
+}
+
+func TestService_VerifyRehash(t *testing.T) {
+	argon2Hasher := NewArgon2idHasher(Argon2idParams{Time: 1, Memory: 1024, Threads: 1})
+
+	subtests := []struct {
+		name       string
+		oldService *Service
+		newService *Service
+		wantPrefix string
+	}{
+		{
+			name:       "bcrypt cost changed",
+			oldService: NewService(NewBcryptHasher(4)),
+			newService: NewService(NewBcryptHasher(5)),
+			wantPrefix: "$2a$05$",
+		},
+		{
+			name:       "argon2id params changed",
+			oldService: NewService(argon2Hasher),
+			newService: NewService(NewArgon2idHasher(Argon2idParams{Time: 2, Memory: 1024, Threads: 1})),
+			wantPrefix: "$argon2id$v=19$m=1024,t=2,p=1$",
+		},
+		{
+			name:       "bcrypt to argon2id",
+			oldService: NewService(NewBcryptHasher(4)),
+			newService: NewService(argon2Hasher, NewBcryptHasher(4)),
+			wantPrefix: "$argon2id$",
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			hash, err := subtest.oldService.Hash("password123")
+			if err != nil {
+				t.Fatalf("unexpected hash error: %v", err)
+			}
+
+			rehashed, err := subtest.newService.Verify(hash, "password123")
+			if err != nil {
+				t.Fatalf("expected password to verify, got: %v", err)
+			}
+			if !strings.HasPrefix(rehashed, subtest.wantPrefix) {
+				t.Errorf("expected rehash with prefix %q, got: %q", subtest.wantPrefix, rehashed)
+			}
+		})
+	}
+}
+
+func TestService_VerifyUnknownHash(t *testing.T) {
+	svc := NewService(NewBcryptHasher(4))
+
+	for _, hash := range []string{"$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$aGFzaA", ""} {
+		if _, err := svc.Verify(hash, hash); !errors.Is(err, ErrUnknownHash) {
+			t.Errorf("expected error: %v, got: %v", ErrUnknownHash, err)
+		}
+	}
+}
+
+func TestService_VerifyLegacyPlaintext(t *testing.T) {
+	svc := NewService(NewBcryptHasher(4))
+
+	rehashed, err := svc.Verify("password123", "password123")
+	if err != nil {
+		t.Fatalf("expected password to verify, got: %v", err)
+	}
+	if !strings.HasPrefix(rehashed, "$2a$04$") {
+		t.Errorf("expected rehash with prefix %q, got: %q", "$2a$04$", rehashed)
+	}
+
+	if _, err := svc.Verify("password123", "wrong"); !errors.Is(err, ErrMismatch) {
+		t.Errorf("expected error: %v, got: %v", ErrMismatch, err)
+	}
+}
+
+func TestBcryptHasher_TooLong(t *testing.T) {
+	_, err := NewBcryptHasher(4).Hash(strings.Repeat("a", 73))
+	if !errors.Is(err, ErrTooLong) {
+		t.Errorf("expected error: %v, got: %v", ErrTooLong, err)
+	}
+}
@@ -0,0 +1,287 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	// ErrInvalidPatch is returned for malformed patch documents.
+	ErrInvalidPatch = errors.New("invalid patch document")
+	// ErrCannotApply is returned for well-formed patches that do not fit
+	// the target document, e.g. because a path does not exist.
+	ErrCannotApply = errors.New("patch cannot be applied")
+	// ErrTestFailed is returned when a JSON Patch test operation fails.
+	ErrTestFailed = errors.New("patch test operation failed")
+)
+
+// Merge applies an RFC 7396 merge patch to doc.
+func Merge(doc, patch []byte) ([]byte, error) {
+	var target, p any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("invalid target document: %w", err)
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+	return json.Marshal(mergeValue(target, p))
+}
+
+func mergeValue(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = map[string]any{}
+	}
+	for key, value := range p {
+		if value == nil {
+			delete(t, key)
+			continue
+		}
+		t[key] = mergeValue(t[key], value)
+	}
+	return t
+}
+
+// Operation is a single RFC 6902 operation. Only add, remove, replace and
+// test are supported.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Apply applies an RFC 6902 JSON Patch to doc. Operations are applied in
+// order and the patch is atomic: on error doc is left untouched.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var ops []Operation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+	}
+
+	var target any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, fmt.Errorf("invalid target document: %w", err)
+	}
+
+	for i, op := range ops {
+		var err error
+		target, err = applyOperation(target, op)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+func applyOperation(doc any, op Operation) (any, error) {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value any
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, fmt.Errorf("%w: %s requires a value", ErrInvalidPatch, op.Op)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidPatch, err)
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return set(doc, tokens, value, true)
+	case "replace":
+		return set(doc, tokens, value, false)
+	case "remove":
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("%w: cannot remove the whole document", ErrCannotApply)
+		}
+		return remove(doc, tokens)
+	case "test":
+		got, err := get(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(got, value) {
+			return nil, fmt.Errorf("%w: %s", ErrTestFailed, op.Path)
+		}
+		return doc, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported op %q", ErrInvalidPatch, op.Op)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped reference
+// tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: invalid path %q", ErrInvalidPatch, pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func get(doc any, tokens []string) (any, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]any:
+			child, ok := node[token]
+			if !ok {
+				return nil, pathNotFound(token)
+			}
+			doc = child
+		case []any:
+			i, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, pathNotFound(token)
+		}
+	}
+	return doc, nil
+}
+
+// set stores value at tokens and returns the updated doc. When insert is
+// false the target location must already exist.
+func set(doc any, tokens []string, value any, insert bool) (any, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+
+	switch node := doc.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			if _, ok := node[token]; !ok && !insert {
+				return nil, pathNotFound(token)
+			}
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, pathNotFound(token)
+		}
+		child, err := set(child, rest, value, insert)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+
+	case []any:
+		if len(rest) == 0 && insert {
+			if token == "-" {
+				return append(node, value), nil
+			}
+			i, err := arrayIndex(token, len(node)+1)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		i, err := arrayIndex(token, len(node))
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			node[i] = value
+			return node, nil
+		}
+		child, err := set(node[i], rest, value, insert)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+
+	default:
+		return nil, pathNotFound(token)
+	}
+}
+
+func remove(doc any, tokens []string) (any, error) {
+	token, rest := tokens[0], tokens[1:]
+
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[token]
+		if !ok {
+			return nil, pathNotFound(token)
+		}
+		if len(rest) == 0 {
+			delete(node, token)
+			return node, nil
+		}
+		child, err := remove(child, rest)
+		if err != nil {
+			return nil, err
+		}
+		node[token] = child
+		return node, nil
+
+	case []any:
+		i, err := arrayIndex(token, len(node))
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) == 0 {
+			return append(node[:i], node[i+1:]...), nil
+		}
+		child, err := remove(node[i], rest)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+
+	default:
+		return nil, pathNotFound(token)
+	}
+}
+
+// arrayIndex parses an array reference token, which must be below n.
+func arrayIndex(token string, n int) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrCannotApply, token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i >= n {
+		return 0, fmt.Errorf("%w: invalid array index %q", ErrCannotApply, token)
+	}
+	return i, nil
+}
+
+func pathNotFound(token string) error {
+	return fmt.Errorf("%w: path element %q not found", ErrCannotApply, token)
+}
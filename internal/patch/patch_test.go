@@ -0,0 +1,169 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func jsonEqual(t *testing.T, want, got []byte) bool {
+	t.Helper()
+	var w, g any
+	if err := json.Unmarshal(want, &w); err != nil {
+		t.Fatalf("invalid expected json: %v", err)
+	}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatalf("invalid result json: %v", err)
+	}
+	return reflect.DeepEqual(w, g)
+}
+
+func TestMerge(t *testing.T) {
+	// Cases taken from RFC 7396, appendix A.
+	subtests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{doc: `{"a":"b"}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"b":"c"}`, want: `{"a":"b","b":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"a":null}`, want: `{}`},
+		{doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, want: `{"b":"c"}`},
+		{doc: `{"a":["b"]}`, patch: `{"a":"c"}`, want: `{"a":"c"}`},
+		{doc: `{"a":"c"}`, patch: `{"a":["b"]}`, want: `{"a":["b"]}`},
+		{doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, want: `{"a":{"b":"d"}}`},
+		{doc: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, want: `{"a":[1]}`},
+		{doc: `{"e":null}`, patch: `{"a":1}`, want: `{"e":null,"a":1}`},
+		{doc: `[1,2]`, patch: `{"a":"b","c":null}`, want: `{"a":"b"}`},
+		{doc: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, want: `{"a":{"bb":{}}}`},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.patch, func(t *testing.T) {
+			got, err := Merge([]byte(subtest.doc), []byte(subtest.patch))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !jsonEqual(t, []byte(subtest.want), got) {
+				t.Errorf("expected: %s, got: %s", subtest.want, got)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	subtests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "add member",
+			doc:   `{"foo":"bar"}`,
+			patch: `[{"op":"add","path":"/baz","value":"qux"}]`,
+			want:  `{"foo":"bar","baz":"qux"}`,
+		},
+		{
+			name:  "add array element",
+			doc:   `{"foo":["bar","baz"]}`,
+			patch: `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			want:  `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:  "append to array",
+			doc:   `{"foo":["bar"]}`,
+			patch: `[{"op":"add","path":"/foo/-","value":["abc"]}]`,
+			want:  `{"foo":["bar",["abc"]]}`,
+		},
+		{
+			name:  "remove member",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"remove","path":"/baz"}]`,
+			want:  `{"foo":"bar"}`,
+		},
+		{
+			name:  "remove array element",
+			doc:   `{"foo":["bar","qux","baz"]}`,
+			patch: `[{"op":"remove","path":"/foo/1"}]`,
+			want:  `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:  "replace value",
+			doc:   `{"baz":"qux","foo":"bar"}`,
+			patch: `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			want:  `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:  "escaped pointer",
+			doc:   `{"a/b":1,"m~n":2}`,
+			patch: `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`,
+			want:  `{"a/b":3}`,
+		},
+		{
+			name:  "test then replace",
+			doc:   `{"baz":"qux","foo":["a",2,"c"]}`,
+			patch: `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2},{"op":"replace","path":"/baz","value":"x"}]`,
+			want:  `{"baz":"x","foo":["a",2,"c"]}`,
+		},
+		{
+			name:    "test failure",
+			doc:     `{"baz":"qux"}`,
+			patch:   `[{"op":"test","path":"/baz","value":"bar"}]`,
+			wantErr: ErrTestFailed,
+		},
+		{
+			name:    "replace missing member",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"replace","path":"/baz","value":"qux"}]`,
+			wantErr: ErrCannotApply,
+		},
+		{
+			name:    "add to nonexistent target",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			wantErr: ErrCannotApply,
+		},
+		{
+			name:    "array index out of bounds",
+			doc:     `{"foo":["bar"]}`,
+			patch:   `[{"op":"add","path":"/foo/2","value":"qux"}]`,
+			wantErr: ErrCannotApply,
+		},
+		{
+			name:    "missing value",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"add","path":"/baz"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "unsupported op",
+			doc:     `{"foo":"bar"}`,
+			patch:   `[{"op":"move","from":"/foo","path":"/baz"}]`,
+			wantErr: ErrInvalidPatch,
+		},
+		{
+			name:    "not an array",
+			doc:     `{"foo":"bar"}`,
+			patch:   `{"op":"add","path":"/baz","value":1}`,
+			wantErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			got, err := Apply([]byte(subtest.doc), []byte(subtest.patch))
+			if !errors.Is(err, subtest.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", subtest.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if !jsonEqual(t, []byte(subtest.want), got) {
+				t.Errorf("expected: %s, got: %s", subtest.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Package purge permanently removes users that stayed soft-deleted past
+// the retention window
+package purge
+
+import (
+	"context"
+	"errors"
+	"go-crud/internal/config"
+	"go-crud/internal/domain"
+	"log/slog"
+	"time"
+)
+
+type Job struct {
+	users     domain.UserRepository
+	retention time.Duration
+	interval  time.Duration
+	logger    *slog.Logger
+	now       func() time.Time
+}
+
+func NewJob(users domain.UserRepository, cfg config.PurgeConfig, logger *slog.Logger) *Job {
+	return &Job{
+		users:     users,
+		retention: cfg.Retention,
+		interval:  cfg.Interval,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// Run purges once right away and then every interval until ctx is done.
+// It returns immediately when retention or interval is not positive.
+func (j *Job) Run(ctx context.Context) {
+	if j.retention <= 0 || j.interval <= 0 {
+		j.logger.InfoContext(ctx, "user purge disabled")
+		return
+	}
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce removes the users deleted more than retention ago.
+func (j *Job) RunOnce(ctx context.Context) (int64, error) {
+	cutoff := j.now().Add(-j.retention)
+
+	purged, err := j.users.Purge(ctx, cutoff)
+	if errors.Is(err, domain.ErrCanceled) {
+		// Shutting down; the next run picks up the rest.
+		j.logger.InfoContext(ctx, "user purge canceled", "purged", purged)
+		return purged, err
+	}
+	if err != nil {
+		j.logger.ErrorContext(ctx, "user purge failed", "purged", purged, "error", err)
+		return purged, err
+	}
+	if purged > 0 {
+		j.logger.InfoContext(ctx, "purged deleted users", "purged", purged, "deleted_before", cutoff)
+	}
+	return purged, nil
+}
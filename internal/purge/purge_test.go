@@ -0,0 +1,93 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"go-crud/internal/config"
+	"go-crud/internal/domain"
+	"go-crud/internal/logging"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeUserRepo struct {
+	domain.UserRepository
+	mu      sync.Mutex
+	cutoffs []time.Time
+	err     error
+}
+
+func (f *fakeUserRepo) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cutoffs = append(f.cutoffs, deletedBefore)
+	return 2, f.err
+}
+
+func (f *fakeUserRepo) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.cutoffs)
+}
+
+func TestJob_RunOnce(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	repo := &fakeUserRepo{}
+	job := NewJob(repo, config.PurgeConfig{Retention: 24 * time.Hour, Interval: time.Hour}, logging.Discard())
+	job.now = func() time.Time { return now }
+
+	purged, err := job.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != 2 {
+		t.Errorf("expected purged: %d, got: %d", 2, purged)
+	}
+	if want := now.Add(-24 * time.Hour); len(repo.cutoffs) != 1 || !repo.cutoffs[0].Equal(want) {
+		t.Errorf("expected cutoff: %v, got: %v", want, repo.cutoffs)
+	}
+
+	repo.err = errors.New("connection lost")
+	if _, err := job.RunOnce(context.Background()); !errors.Is(err, repo.err) {
+		t.Errorf("expected error: %v, got: %v", repo.err, err)
+	}
+}
+
+func TestJob_Run(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		repo := &fakeUserRepo{}
+		NewJob(repo, config.PurgeConfig{Retention: 0, Interval: time.Hour}, logging.Discard()).Run(context.Background())
+		if repo.calls() != 0 {
+			t.Errorf("expected no purge, got: %d", repo.calls())
+		}
+	})
+
+	t.Run("stops with context", func(t *testing.T) {
+		repo := &fakeUserRepo{}
+		job := NewJob(repo, config.PurgeConfig{Retention: time.Hour, Interval: time.Millisecond}, logging.Discard())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			job.Run(ctx)
+		}()
+
+		deadline := time.After(time.Second)
+		for repo.calls() < 2 {
+			select {
+			case <-deadline:
+				t.Fatalf("expected repeated purges, got: %d", repo.calls())
+			case <-time.After(time.Millisecond):
+			}
+		}
+
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expected Run to return after cancel")
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// dialect holds what differs between the SQL databases for the query
+// builders that the implementations share.
+type dialect struct {
+	// system identifies the database in trace spans.
+	system attribute.KeyValue
+	// numbered selects $1, $2, ... placeholders instead of ?.
+	numbered bool
+	// backslash is a string literal holding a single backslash, used as
+	// the LIKE escape character.
+	backslash string
+	// resolveError maps driver errors to domain errors.
+	resolveError func(error) error
+	// timeArg, when set, converts time arguments to the representation
+	// the database compares correctly.
+	timeArg func(time.Time) any
+	// now is the SQL expression for the current time.
+	now string
+	// returning reports support for INSERT ... RETURNING.
+	returning bool
+	// deleteLimit reports support for DELETE ... LIMIT.
+	deleteLimit bool
+}
+
+// sqliteNow is the current time in the layout of sqliteTimeLayout. SQLite
+// has no NOW() and CURRENT_TIMESTAMP drops the milliseconds.
+const sqliteNow = "strftime('%Y-%m-%d %H:%M:%f', 'now')"
+
+var (
+	mysqlDialect = dialect{
+		system:       semconv.DBSystemNameMySQL,
+		backslash:    `'\\'`,
+		resolveError: resolveSQLError,
+		now:          "NOW()",
+		deleteLimit:  true,
+	}
+	postgresDialect = dialect{
+		system:       semconv.DBSystemNamePostgreSQL,
+		numbered:     true,
+		backslash:    `'\'`,
+		resolveError: resolvePostgresError,
+		now:          "NOW()",
+		returning:    true,
+	}
+	sqliteDialect = dialect{
+		system:       semconv.DBSystemNameSQLite,
+		backslash:    `'\'`,
+		resolveError: resolveSQLiteError,
+		timeArg:      sqliteTime,
+		now:          sqliteNow,
+		returning:    true,
+	}
+)
+
+// queryError prefers the context error over the driver error once the
+// context is done, since drivers report cancelled queries differently.
+func (d dialect) queryError(ctx context.Context, e error) error {
+	if e != nil && ctx.Err() != nil {
+		return d.resolveError(ctx.Err())
+	}
+	return d.resolveError(e)
+}
+
+// queryArgs collects the arguments of a query that is built up piece by
+// piece and hands out the matching placeholders.
+type queryArgs struct {
+	dialect dialect
+	values  []any
+}
+
+// add appends v and returns its placeholder.
+func (a *queryArgs) add(v any) string {
+	if t, ok := v.(time.Time); ok && a.dialect.timeArg != nil {
+		v = a.dialect.timeArg(t)
+	}
+	a.values = append(a.values, v)
+	if a.dialect.numbered {
+		return "$" + strconv.Itoa(len(a.values))
+	}
+	return "?"
+}
@@ -1,26 +1,170 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"go-crud/internal/domain"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/go-sql-driver/mysql"
 )
 
-func resolveSQLError(e error) error {
-	if e == nil {
-		return nil
+// MySQL server error numbers, see
+// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	mysqlErrServerShutdown      = 1053
+	mysqlErrBadNull             = 1048
+	mysqlErrDupEntry            = 1062
+	mysqlErrLockWaitTimeout     = 1205
+	mysqlErrLockDeadlock        = 1213
+	mysqlErrNoReferencedRow     = 1216
+	mysqlErrRowIsReferenced     = 1217
+	mysqlErrOptionPrevents      = 1290
+	mysqlErrDataTooLong         = 1406
+	mysqlErrRowIsReferenced2    = 1451
+	mysqlErrNoReferencedRow2    = 1452
+	mysqlErrReadOnlyTransaction = 1792
+	mysqlErrReadOnlyMode        = 1836
+	mysqlErrConnectionKilled    = 1927
+)
+
+// uniqueKeyFields maps unique indexes to the field they keep unique. The
+// names are the same for every driver.
+var uniqueKeyFields = map[string]string{
+	"users_email_unique":    "email",
+	"users_username_unique": "username",
+}
+
+var (
+	duplicateKeyPattern = regexp.MustCompile(`for key '([^']+)'$`)
+	columnPattern       = regexp.MustCompile(`(?i)column '([^']+)'`)
+)
+
+// resolveCommonError maps the errors that do not depend on the driver.
+// ok is false when e needs driver specific handling.
+func resolveCommonError(e error) (err error, ok bool) {
+	switch {
+	case e == nil:
+		return nil, true
+	case e == sql.ErrNoRows:
+		return domain.ErrNotFound, true
+	case errors.Is(e, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", domain.ErrTimeout, e), true
+	case errors.Is(e, context.Canceled):
+		return fmt.Errorf("%w: %w", domain.ErrCanceled, e), true
+	case isConnectionError(e):
+		return fmt.Errorf("%w: %w", domain.ErrUnavailable, e), true
 	}
-	if e == sql.ErrNoRows {
-		return domain.ErrNotFound
+	return nil, false
+}
+
+func resolveSQLError(e error) error {
+	if err, ok := resolveCommonError(e); ok {
+		return err
 	}
-	if mysqlErr, ok := e.(*mysql.MySQLError); ok {
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(e, &mysqlErr) {
 		switch mysqlErr.Number {
-		case 1062:
+		case mysqlErrDupEntry:
+			if field, ok := uniqueKeyFields[duplicateKey(mysqlErr.Message)]; ok {
+				return &domain.ConstraintError{Field: field, Err: domain.ErrAlreadyExists}
+			}
 			return domain.ErrAlreadyExists
+		case mysqlErrDataTooLong:
+			return columnError(mysqlErr, domain.ErrValueTooLong)
+		case mysqlErrBadNull:
+			return columnError(mysqlErr, domain.ErrRequiredValue)
+		case mysqlErrRowIsReferenced, mysqlErrRowIsReferenced2:
+			return fmt.Errorf("%w: %w", domain.ErrReferenced, e)
+		case mysqlErrNoReferencedRow, mysqlErrNoReferencedRow2:
+			return fmt.Errorf("%w: %w", domain.ErrInvalidReference, e)
+		case mysqlErrLockDeadlock:
+			return fmt.Errorf("%w: %w", domain.ErrDeadlock, e)
+		case mysqlErrLockWaitTimeout:
+			return fmt.Errorf("%w: %w", domain.ErrLockTimeout, e)
+		case mysqlErrReadOnlyTransaction, mysqlErrReadOnlyMode:
+			return fmt.Errorf("%w: %w", domain.ErrReadOnly, e)
+		case mysqlErrOptionPrevents:
+			// Also raised for options other than --read-only and
+			// --super-read-only.
+			if strings.Contains(mysqlErr.Message, "read-only") {
+				return fmt.Errorf("%w: %w", domain.ErrReadOnly, e)
+			}
+		case mysqlErrServerShutdown, mysqlErrConnectionKilled:
+			return fmt.Errorf("%w: %w", domain.ErrUnavailable, e)
 		}
 	}
 
 	return fmt.Errorf("unexpected db error: %w", e)
 }
+
+// isConnectionError reports whether e means the connection to the server
+// was lost or could not be established.
+func isConnectionError(e error) bool {
+	var netErr *net.OpError
+	return errors.Is(e, driver.ErrBadConn) ||
+		errors.Is(e, mysql.ErrInvalidConn) ||
+		errors.Is(e, sql.ErrConnDone) ||
+		errors.As(e, &netErr)
+}
+
+// duplicateKey extracts the index name from a duplicate entry message.
+// MySQL 8 qualifies it with the table name ("users.users_email_unique").
+func duplicateKey(message string) string {
+	m := duplicateKeyPattern.FindStringSubmatch(message)
+	if m == nil {
+		return ""
+	}
+	key := m[1]
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		key = key[i+1:]
+	}
+	return key
+}
+
+// columnError attaches the column named in the error message to err.
+func columnError(mysqlErr *mysql.MySQLError, err error) error {
+	m := columnPattern.FindStringSubmatch(mysqlErr.Message)
+	if m == nil {
+		return fmt.Errorf("%w: %w", err, mysqlErr)
+	}
+	return &domain.ConstraintError{Field: m[1], Err: err}
+}
+
+// resolveQueryError is dialect.queryError for MySQL.
+func resolveQueryError(ctx context.Context, e error) error {
+	return mysqlDialect.queryError(ctx, e)
+}
+
+// isClientError reports whether err is caused by the caller, through the
+// data it sent or by giving up on the operation, rather than by a failing
+// database.
+func isClientError(err error) bool {
+	return errors.Is(err, domain.ErrNotFound) || errors.Is(err, domain.ErrAlreadyExists) ||
+		errors.Is(err, domain.ErrCanceled) ||
+		errors.Is(err, domain.ErrInvalidCursor) || errors.Is(err, domain.ErrValueTooLong) ||
+		errors.Is(err, domain.ErrRequiredValue) || errors.Is(err, domain.ErrReferenced) ||
+		errors.Is(err, domain.ErrInvalidReference)
+}
+
+// logOp records a finished repository operation. Expected outcomes are
+// logged at debug level; timeouts and unexpected errors at warn level.
+func logOp(ctx context.Context, logger *slog.Logger, operation string, start time.Time, err error) {
+	attrs := []any{"operation", operation, "duration", time.Since(start)}
+	switch {
+	case err == nil:
+		logger.DebugContext(ctx, "db operation", attrs...)
+	case isClientError(err):
+		logger.DebugContext(ctx, "db operation", append(attrs, "error", err)...)
+	default:
+		logger.WarnContext(ctx, "db operation failed", append(attrs, "error", err)...)
+	}
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"go-crud/internal/domain"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// PostgreSQL SQLSTATE codes, see
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgErrStringDataRightTruncation = "22001"
+	pgErrNotNullViolation          = "23502"
+	pgErrForeignKeyViolation       = "23503"
+	pgErrUniqueViolation           = "23505"
+	pgErrReadOnlySQLTransaction    = "25006"
+	pgErrSerializationFailure      = "40001"
+	pgErrDeadlockDetected          = "40P01"
+	pgErrLockNotAvailable          = "55P03"
+	pgErrQueryCanceled             = "57014"
+	pgErrAdminShutdown             = "57P01"
+	pgErrCrashShutdown             = "57P02"
+	pgErrCannotConnectNow          = "57P03"
+	// pgErrClassConnectionException prefixes the connection exception
+	// codes.
+	pgErrClassConnectionException = "08"
+)
+
+func resolvePostgresError(e error) error {
+	if err, ok := resolveCommonError(e); ok {
+		return err
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(e, &connectErr) {
+		return fmt.Errorf("%w: %w", domain.ErrUnavailable, e)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(e, &pgErr) {
+		switch pgErr.Code {
+		case pgErrUniqueViolation:
+			if field, ok := uniqueKeyFields[pgErr.ConstraintName]; ok {
+				return &domain.ConstraintError{Field: field, Err: domain.ErrAlreadyExists}
+			}
+			return domain.ErrAlreadyExists
+		case pgErrStringDataRightTruncation:
+			// PostgreSQL does not report the column of a too long value.
+			return fmt.Errorf("%w: %w", domain.ErrValueTooLong, e)
+		case pgErrNotNullViolation:
+			return &domain.ConstraintError{Field: pgErr.ColumnName, Err: domain.ErrRequiredValue}
+		case pgErrForeignKeyViolation:
+			// One code covers both directions; only the message tells
+			// whether the referenced or the referencing row was written.
+			if strings.HasPrefix(pgErr.Message, "update or delete on table") {
+				return fmt.Errorf("%w: %w", domain.ErrReferenced, e)
+			}
+			return fmt.Errorf("%w: %w", domain.ErrInvalidReference, e)
+		case pgErrDeadlockDetected, pgErrSerializationFailure:
+			// Serialization failures are retried like deadlocks.
+			return fmt.Errorf("%w: %w", domain.ErrDeadlock, e)
+		case pgErrLockNotAvailable:
+			return fmt.Errorf("%w: %w", domain.ErrLockTimeout, e)
+		case pgErrQueryCanceled:
+			// Raised by statement_timeout.
+			return fmt.Errorf("%w: %w", domain.ErrTimeout, e)
+		case pgErrReadOnlySQLTransaction:
+			return fmt.Errorf("%w: %w", domain.ErrReadOnly, e)
+		case pgErrAdminShutdown, pgErrCrashShutdown, pgErrCannotConnectNow:
+			return fmt.Errorf("%w: %w", domain.ErrUnavailable, e)
+		}
+		if strings.HasPrefix(pgErr.Code, pgErrClassConnectionException) {
+			return fmt.Errorf("%w: %w", domain.ErrUnavailable, e)
+		}
+	}
+
+	return fmt.Errorf("unexpected db error: %w", e)
+}
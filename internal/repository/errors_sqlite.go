@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"go-crud/internal/domain"
+	"regexp"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteTimeLayout matches the timestamps the SQLite migrations and
+// queries store, see sqliteNow.
+const sqliteTimeLayout = "2006-01-02 15:04:05.000"
+
+// sqliteTime formats t the way SQLite stores timestamps. SQLite compares
+// them as text, so every bound time must use the same layout and zone.
+func sqliteTime(t time.Time) any {
+	return t.UTC().Format(sqliteTimeLayout)
+}
+
+// lengthCheckFields maps the CHECK constraints that stand in for column
+// lengths in SQLite to the field they limit.
+var lengthCheckFields = map[string]string{
+	"users_email_length":    "email",
+	"users_username_length": "username",
+	"users_password_length": "password",
+	"users_role_length":     "role",
+}
+
+// sqliteConstraintPattern extracts the column ("users.email") or the
+// constraint name ("users_email_length") from a constraint error.
+var sqliteConstraintPattern = regexp.MustCompile(`(?:UNIQUE|NOT NULL|CHECK) constraint failed: (?:(\w+)\.)?(\w+)`)
+
+func resolveSQLiteError(e error) error {
+	if err, ok := resolveCommonError(e); ok {
+		return err
+	}
+
+	var sqliteErr *sqlite.Error
+	if !errors.As(e, &sqliteErr) {
+		return fmt.Errorf("unexpected db error: %w", e)
+	}
+
+	table, name := "", ""
+	if m := sqliteConstraintPattern.FindStringSubmatch(sqliteErr.Error()); m != nil {
+		table, name = m[1], m[2]
+	}
+
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		// SQLite names the columns rather than the index; the indexes
+		// follow the <table>_<column>_unique convention.
+		if field, ok := uniqueKeyFields[table+"_"+name+"_unique"]; ok {
+			return &domain.ConstraintError{Field: field, Err: domain.ErrAlreadyExists}
+		}
+		return domain.ErrAlreadyExists
+	case sqlite3.SQLITE_CONSTRAINT_NOTNULL:
+		return &domain.ConstraintError{Field: name, Err: domain.ErrRequiredValue}
+	case sqlite3.SQLITE_CONSTRAINT_CHECK:
+		if field, ok := lengthCheckFields[name]; ok {
+			return &domain.ConstraintError{Field: field, Err: domain.ErrValueTooLong}
+		}
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		// SQLite does not tell which side of the key was written.
+		return fmt.Errorf("%w: %w", domain.ErrInvalidReference, e)
+	}
+
+	// Primary result codes are the low byte of extended ones.
+	switch sqliteErr.Code() & 0xff {
+	case sqlite3.SQLITE_BUSY:
+		return fmt.Errorf("%w: %w", domain.ErrLockTimeout, e)
+	case sqlite3.SQLITE_LOCKED:
+		return fmt.Errorf("%w: %w", domain.ErrDeadlock, e)
+	case sqlite3.SQLITE_READONLY:
+		return fmt.Errorf("%w: %w", domain.ErrReadOnly, e)
+	case sqlite3.SQLITE_TOOBIG:
+		return fmt.Errorf("%w: %w", domain.ErrValueTooLong, e)
+	case sqlite3.SQLITE_CANTOPEN:
+		return fmt.Errorf("%w: %w", domain.ErrUnavailable, e)
+	}
+
+	return fmt.Errorf("unexpected db error: %w", e)
+}
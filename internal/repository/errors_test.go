@@ -1,12 +1,16 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"go-crud/internal/domain"
 	"testing"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func TestResolveSQLError(t *testing.T) {
@@ -30,6 +34,16 @@ func TestResolveSQLError(t *testing.T) {
 			inputErr: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"},
 			expected: domain.ErrAlreadyExists,
 		},
+		{
+			name:     "context deadline exceeded",
+			inputErr: context.DeadlineExceeded,
+			expected: fmt.Errorf("operation timed out: context deadline exceeded"),
+		},
+		{
+			name:     "context canceled",
+			inputErr: context.Canceled,
+			expected: fmt.Errorf("operation canceled: context canceled"),
+		},
 		{
 			name:     "generic database error",
 			inputErr: fmt.Errorf("connection lost"),
@@ -56,3 +70,188 @@ func TestResolveSQLError(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveSQLError_Typed(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputErr  error
+		expected  error
+		wantField string
+	}{
+		{
+			name:      "duplicate email",
+			inputErr:  &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'a@b.c-1' for key 'users.users_email_unique'"},
+			expected:  domain.ErrAlreadyExists,
+			wantField: "email",
+		},
+		{
+			name:      "duplicate username without table prefix",
+			inputErr:  &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'alice-1' for key 'users_username_unique'"},
+			expected:  domain.ErrAlreadyExists,
+			wantField: "username",
+		},
+		{
+			name:     "duplicate on unknown key",
+			inputErr: &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'x' for key 'refresh_tokens.token_hash'"},
+			expected: domain.ErrAlreadyExists,
+		},
+		{
+			name:      "data too long",
+			inputErr:  &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'username' at row 1"},
+			expected:  domain.ErrValueTooLong,
+			wantField: "username",
+		},
+		{
+			name:      "not null",
+			inputErr:  &mysql.MySQLError{Number: 1048, Message: "Column 'email' cannot be null"},
+			expected:  domain.ErrRequiredValue,
+			wantField: "email",
+		},
+		{
+			name:     "row is referenced",
+			inputErr: &mysql.MySQLError{Number: 1451, Message: "Cannot delete or update a parent row: a foreign key constraint fails"},
+			expected: domain.ErrReferenced,
+		},
+		{
+			name:     "no referenced row",
+			inputErr: &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails"},
+			expected: domain.ErrInvalidReference,
+		},
+		{
+			name:     "deadlock",
+			inputErr: &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"},
+			expected: domain.ErrDeadlock,
+		},
+		{
+			name:     "lock wait timeout",
+			inputErr: &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"},
+			expected: domain.ErrLockTimeout,
+		},
+		{
+			name:     "read-only server",
+			inputErr: &mysql.MySQLError{Number: 1290, Message: "The MySQL server is running with the --read-only option so it cannot execute this statement"},
+			expected: domain.ErrReadOnly,
+		},
+		{
+			name:     "read-only transaction",
+			inputErr: &mysql.MySQLError{Number: 1792, Message: "Cannot execute statement in a READ ONLY transaction."},
+			expected: domain.ErrReadOnly,
+		},
+		{
+			name:     "bad connection",
+			inputErr: fmt.Errorf("exec: %w", driver.ErrBadConn),
+			expected: domain.ErrUnavailable,
+		},
+		{
+			name:     "invalid connection",
+			inputErr: mysql.ErrInvalidConn,
+			expected: domain.ErrUnavailable,
+		},
+		{
+			name:     "connection killed",
+			inputErr: &mysql.MySQLError{Number: 1927, Message: "Connection was killed"},
+			expected: domain.ErrUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := resolveSQLError(test.inputErr)
+
+			if !errors.Is(result, test.expected) {
+				t.Fatalf("expected error: %v, got: %v", test.expected, result)
+			}
+
+			var constraintErr *domain.ConstraintError
+			errors.As(result, &constraintErr)
+			field := ""
+			if constraintErr != nil {
+				field = constraintErr.Field
+			}
+			if field != test.wantField {
+				t.Errorf("expected field: %q, got: %q", test.wantField, field)
+			}
+		})
+	}
+}
+
+func TestResolvePostgresError(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputErr  error
+		expected  error
+		wantField string
+	}{
+		{
+			name:      "unique violation on email",
+			inputErr:  &pgconn.PgError{Code: "23505", ConstraintName: "users_email_unique"},
+			expected:  domain.ErrAlreadyExists,
+			wantField: "email",
+		},
+		{
+			name:      "not null violation",
+			inputErr:  &pgconn.PgError{Code: "23502", ColumnName: "username"},
+			expected:  domain.ErrRequiredValue,
+			wantField: "username",
+		},
+		{
+			name:     "value too long",
+			inputErr: &pgconn.PgError{Code: "22001"},
+			expected: domain.ErrValueTooLong,
+		},
+		{
+			name:     "row is referenced",
+			inputErr: &pgconn.PgError{Code: "23503", Message: `update or delete on table "users" violates foreign key constraint "fk_refresh_tokens_user_id" on table "refresh_tokens"`},
+			expected: domain.ErrReferenced,
+		},
+		{
+			name:     "no referenced row",
+			inputErr: &pgconn.PgError{Code: "23503", Message: `insert or update on table "refresh_tokens" violates foreign key constraint "fk_refresh_tokens_user_id"`},
+			expected: domain.ErrInvalidReference,
+		},
+		{
+			name:     "deadlock",
+			inputErr: &pgconn.PgError{Code: "40P01"},
+			expected: domain.ErrDeadlock,
+		},
+		{
+			name:     "lock not available",
+			inputErr: &pgconn.PgError{Code: "55P03"},
+			expected: domain.ErrLockTimeout,
+		},
+		{
+			name:     "read-only transaction",
+			inputErr: &pgconn.PgError{Code: "25006"},
+			expected: domain.ErrReadOnly,
+		},
+		{
+			name:     "connection failure",
+			inputErr: &pgconn.PgError{Code: "08006"},
+			expected: domain.ErrUnavailable,
+		},
+		{
+			name:     "statement timeout",
+			inputErr: &pgconn.PgError{Code: "57014"},
+			expected: domain.ErrTimeout,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := resolvePostgresError(test.inputErr)
+
+			if !errors.Is(result, test.expected) {
+				t.Fatalf("expected error: %v, got: %v", test.expected, result)
+			}
+
+			var constraintErr *domain.ConstraintError
+			field := ""
+			if errors.As(result, &constraintErr) {
+				field = constraintErr.Field
+			}
+			if field != test.wantField {
+				t.Errorf("expected field: %q, got: %q", test.wantField, field)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"go-crud/internal/domain"
+	"maps"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// MemoryStore keeps users and refresh tokens in process memory, for tests,
+// demos and DB_DRIVER=memory. It is safe for concurrent use and enforces
+// the same constraints as the SQL schemas.
+//
+// MemoryStore also implements domain.TxManager: a unit of work holds the
+// store exclusively and its changes are undone when it fails. Nested units
+// of work behave like savepoints.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data *memoryData
+	opts []Option
+}
+
+type memoryData struct {
+	users       map[int64]domain.User
+	tokens      map[int64]domain.RefreshToken
+	lastUserID  int64
+	lastTokenID int64
+}
+
+// memoryTxKey marks a context whose unit of work holds a MemoryStore.
+type memoryTxKey struct{}
+
+func NewMemoryStore(opts ...Option) *MemoryStore {
+	return &MemoryStore{
+		data: &memoryData{
+			users:  map[int64]domain.User{},
+			tokens: map[int64]domain.RefreshToken{},
+		},
+		opts: opts,
+	}
+}
+
+// Repositories returns every repository, backed by the store.
+func (s *MemoryStore) Repositories() domain.Repositories {
+	return domain.Repositories{
+		Users:         &MemoryUserRepository{store: s, repoOptions: newRepoOptions(s.opts)},
+		RefreshTokens: &MemoryRefreshTokenRepository{store: s, repoOptions: newRepoOptions(s.opts)},
+	}
+}
+
+func (s *MemoryStore) WithinTx(ctx context.Context, fn func(ctx context.Context, repos domain.Repositories) error) (err error) {
+	if !s.inTx(ctx) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		ctx = context.WithValue(ctx, memoryTxKey{}, s)
+	}
+
+	snapshot := s.data.clone()
+	defer func() {
+		if p := recover(); p != nil {
+			s.data = snapshot
+			panic(p)
+		}
+		if err != nil {
+			s.data = snapshot
+		}
+	}()
+
+	return fn(ctx, s.Repositories())
+}
+
+func (s *MemoryStore) inTx(ctx context.Context) bool {
+	return ctx.Value(memoryTxKey{}) == s
+}
+
+// read runs fn with the store locked for reading, unless ctx belongs to a
+// unit of work that already holds it.
+func (s *MemoryStore) read(ctx context.Context, fn func(d *memoryData) error) error {
+	if err := memoryContextError(ctx); err != nil {
+		return err
+	}
+	if !s.inTx(ctx) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+	return fn(s.data)
+}
+
+// write is like read but locks the store for writing.
+func (s *MemoryStore) write(ctx context.Context, fn func(d *memoryData) error) error {
+	if err := memoryContextError(ctx); err != nil {
+		return err
+	}
+	if !s.inTx(ctx) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	return fn(s.data)
+}
+
+// memoryContextError reports a done context the way the SQL
+// implementations do.
+func memoryContextError(ctx context.Context) error {
+	if ctx.Err() == nil {
+		return nil
+	}
+	if err, ok := resolveCommonError(ctx.Err()); ok {
+		return err
+	}
+	return ctx.Err()
+}
+
+// memoryNow returns the current time at the precision PostgreSQL stores.
+func memoryNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := *d
+	c.users = maps.Clone(d.users)
+	c.tokens = maps.Clone(d.tokens)
+	return &c
+}
+
+// memoryColumnLengths are the column lengths of the users table, checked
+// in column order like the databases do.
+var memoryColumnLengths = []struct {
+	field  string
+	length int
+	value  func(u *domain.User) string
+}{
+	{"email", 100, func(u *domain.User) string { return u.Email }},
+	{"username", 100, func(u *domain.User) string { return u.Username }},
+	{"password", 255, func(u *domain.User) string { return u.Password }},
+	{"role", 20, func(u *domain.User) string { return string(u.Role) }},
+}
+
+// checkUser enforces the column lengths and unique indexes of the users
+// table on u, which is about to be stored.
+func (d *memoryData) checkUser(u *domain.User) error {
+	for _, column := range memoryColumnLengths {
+		if utf8.RuneCountInString(column.value(u)) > column.length {
+			return &domain.ConstraintError{Field: column.field, Err: domain.ErrValueTooLong}
+		}
+	}
+
+	// Like the partial indexes, soft-deleted users take no part.
+	if u.DeletedAt != nil {
+		return nil
+	}
+	for _, other := range d.users {
+		if other.ID == u.ID || other.DeletedAt != nil {
+			continue
+		}
+		if other.Email == u.Email {
+			return &domain.ConstraintError{Field: "email", Err: domain.ErrAlreadyExists}
+		}
+		if other.Username == u.Username {
+			return &domain.ConstraintError{Field: "username", Err: domain.ErrAlreadyExists}
+		}
+	}
+	return nil
+}
+
+// deleteUser removes a user along with its refresh tokens, as the foreign
+// key cascades.
+func (d *memoryData) deleteUser(id int64) {
+	delete(d.users, id)
+	for tokenID, token := range d.tokens {
+		if token.UserID == id {
+			delete(d.tokens, tokenID)
+		}
+	}
+}
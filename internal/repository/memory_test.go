@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-crud/internal/domain"
+	"go-crud/internal/logging"
+	"go-crud/internal/repository/repotest"
+	"testing"
+	"time"
+)
+
+func TestMemoryUserRepository_Contract(t *testing.T) {
+	repotest.TestUserRepository(t, func(t *testing.T) domain.UserRepository {
+		return NewMemoryUserRepository(WithLogger(logging.Discard()))
+	})
+}
+
+func TestMemoryStore_WithinTx(t *testing.T) {
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	subtests := []struct {
+		name      string
+		fn        func(ctx context.Context, store *MemoryStore, repos domain.Repositories) error
+		wantErr   error
+		wantUsers []string
+	}{
+		{
+			name: "commit on success",
+			fn: func(ctx context.Context, _ *MemoryStore, repos domain.Repositories) error {
+				return repos.Users.Create(ctx, &domain.User{Username: "new", Email: "new@email.com"})
+			},
+			wantUsers: []string{"existing", "new"},
+		},
+		{
+			name: "rollback on error",
+			fn: func(ctx context.Context, _ *MemoryStore, repos domain.Repositories) error {
+				if err := repos.Users.Create(ctx, &domain.User{Username: "new", Email: "new@email.com"}); err != nil {
+					return err
+				}
+				return errBoom
+			},
+			wantErr:   errBoom,
+			wantUsers: []string{"existing"},
+		},
+		{
+			name: "nested rollback keeps the outer changes",
+			fn: func(ctx context.Context, store *MemoryStore, repos domain.Repositories) error {
+				if err := repos.Users.Create(ctx, &domain.User{Username: "outer", Email: "outer@email.com"}); err != nil {
+					return err
+				}
+				err := store.WithinTx(ctx, func(ctx context.Context, repos domain.Repositories) error {
+					if err := repos.Users.Create(ctx, &domain.User{Username: "inner", Email: "inner@email.com"}); err != nil {
+						return err
+					}
+					return errBoom
+				})
+				if !errors.Is(err, errBoom) {
+					return fmt.Errorf("expected error: %v, got: %v", errBoom, err)
+				}
+				return nil
+			},
+			wantUsers: []string{"existing", "outer"},
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			if err := store.Repositories().Users.Create(ctx, &domain.User{Username: "existing", Email: "existing@email.com"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err := store.WithinTx(ctx, func(ctx context.Context, repos domain.Repositories) error {
+				return subtest.fn(ctx, store, repos)
+			})
+			if !errors.Is(err, subtest.wantErr) {
+				t.Fatalf("expected error: %v, got: %v", subtest.wantErr, err)
+			}
+
+			list, err := store.Repositories().Users.List(ctx, domain.UserListParams{Limit: 10})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := []string{}
+			for _, user := range list.Users {
+				got = append(got, user.Username)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(subtest.wantUsers) {
+				t.Errorf("expected users: %v, got: %v", subtest.wantUsers, got)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_RollbackOnPanic(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected the panic to propagate")
+			}
+		}()
+		store.WithinTx(ctx, func(ctx context.Context, repos domain.Repositories) error {
+			repos.Users.Create(ctx, &domain.User{Username: "testuser", Email: "test@email.com"})
+			panic("boom")
+		})
+	}()
+
+	if _, err := store.Repositories().Users.GetByLogin(ctx, "testuser"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrNotFound, err)
+	}
+}
+
+func TestMemoryRefreshTokenRepository(t *testing.T) {
+	ctx := context.Background()
+	repos := NewMemoryStore().Repositories()
+
+	user := &domain.User{Username: "testuser", Email: "test@email.com"}
+	if err := repos.Users.Create(ctx, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	token := &domain.RefreshToken{UserID: user.ID, TokenHash: "hash", FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := repos.RefreshTokens.Create(ctx, token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := repos.RefreshTokens.Create(ctx, &domain.RefreshToken{UserID: user.ID + 1, TokenHash: "other"})
+	if !errors.Is(err, domain.ErrInvalidReference) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidReference, err)
+	}
+
+	if err := repos.RefreshTokens.RevokeFamily(ctx, "family"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repos.RefreshTokens.Revoke(ctx, token.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrNotFound, err)
+	}
+
+	// Removing the user cascades to its tokens.
+	if err := repos.Users.HardDelete(ctx, user.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := repos.RefreshTokens.GetByHash(ctx, "hash"); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrNotFound, err)
+	}
+}
+
+type recordingObserver struct {
+	operations []string
+}
+
+func (o *recordingObserver) ObserveQuery(operation string, duration time.Duration, err error) {
+	if err != nil {
+		operation += " " + err.Error()
+	}
+	o.operations = append(o.operations, operation)
+}
+
+func TestWithQueryObserver(t *testing.T) {
+	ctx := context.Background()
+	observer := &recordingObserver{}
+	repo := NewMemoryUserRepository(WithQueryObserver(observer), WithLogger(logging.Discard()))
+
+	repo.Create(ctx, &domain.User{Username: "testuser", Email: "test@email.com"})
+	repo.GetByID(ctx, 2)
+
+	want := []string{OpCreate, OpGetByID + " " + domain.ErrNotFound.Error()}
+	if fmt.Sprint(observer.operations) != fmt.Sprint(want) {
+		t.Errorf("expected operations: %v, got: %v", want, observer.operations)
+	}
+}
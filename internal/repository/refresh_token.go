@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"go-crud/internal/domain"
+)
+
+// RefreshTokenRepository is the SQL implementation of
+// domain.RefreshTokenRepository. Its dialect adapts the queries to MySQL,
+// PostgreSQL or SQLite.
+type RefreshTokenRepository struct {
+	db      DBTX
+	dialect dialect
+	repoOptions
+}
+
+// NewRefreshTokenRepository returns the MySQL refresh token repository.
+func NewRefreshTokenRepository(db DBTX, opts ...Option) domain.RefreshTokenRepository {
+	return &RefreshTokenRepository{db: db, dialect: mysqlDialect, repoOptions: newRepoOptions(opts)}
+}
+
+func (r *RefreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) (err error) {
+	ctx, finish := r.startOp(ctx, OpRefreshTokenCreate)
+	defer func() { finish(err) }()
+
+	args := &queryArgs{dialect: r.dialect}
+	query := `
+	INSERT INTO refresh_tokens (user_id, token_hash, family_id, expires_at, created_at)
+	VALUES (` + args.add(token.UserID) + `, ` + args.add(token.TokenHash) + `, ` + args.add(token.FamilyID) + `, ` +
+		args.add(token.ExpiresAt) + `, ` + r.dialect.now + `)`
+
+	if r.dialect.returning {
+		if err := r.db.QueryRowContext(ctx, query+" RETURNING id", args.values...).Scan(&token.ID); err != nil {
+			return r.dialect.queryError(ctx, err)
+		}
+		return nil
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args.values...)
+	if err != nil {
+		return r.dialect.queryError(ctx, err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return r.dialect.queryError(ctx, err)
+	}
+
+	token.ID = id
+	return nil
+}
+
+func (r *RefreshTokenRepository) GetByHash(ctx context.Context, hash string) (_ *domain.RefreshToken, err error) {
+	ctx, finish := r.startOp(ctx, OpRefreshTokenGetByHash)
+	defer func() { finish(err) }()
+
+	args := &queryArgs{dialect: r.dialect}
+	query := `
+	SELECT id, user_id, token_hash, family_id, expires_at, revoked_at, created_at FROM refresh_tokens
+	WHERE token_hash = ` + args.add(hash)
+
+	var token domain.RefreshToken
+	var revokedAt sql.NullTime
+	err = r.db.QueryRowContext(ctx, query, args.values...).Scan(
+		&token.ID, &token.UserID, &token.TokenHash, &token.FamilyID, &token.ExpiresAt, &revokedAt, &token.CreatedAt,
+	)
+	if err != nil {
+		return nil, r.dialect.queryError(ctx, err)
+	}
+
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+func (r *RefreshTokenRepository) Revoke(ctx context.Context, id int64) (err error) {
+	ctx, finish := r.startOp(ctx, OpRefreshTokenRevoke)
+	defer func() { finish(err) }()
+
+	args := &queryArgs{dialect: r.dialect}
+	query := "UPDATE refresh_tokens SET revoked_at = " + r.dialect.now + " WHERE id = " + args.add(id) + " AND revoked_at IS NULL"
+
+	result, err := r.db.ExecContext(ctx, query, args.values...)
+	if err != nil {
+		return r.dialect.queryError(ctx, err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return r.dialect.queryError(ctx, err)
+	}
+
+	if rowsAffected == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) (err error) {
+	ctx, finish := r.startOp(ctx, OpRefreshTokenRevokeFamily)
+	defer func() { finish(err) }()
+
+	args := &queryArgs{dialect: r.dialect}
+	query := "UPDATE refresh_tokens SET revoked_at = " + r.dialect.now + " WHERE family_id = " + args.add(familyID) + " AND revoked_at IS NULL"
+
+	if _, err := r.db.ExecContext(ctx, query, args.values...); err != nil {
+		return r.dialect.queryError(ctx, err)
+	}
+	return nil
+}
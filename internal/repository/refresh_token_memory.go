@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"go-crud/internal/domain"
+)
+
+// MemoryRefreshTokenRepository is the in-memory implementation of
+// domain.RefreshTokenRepository, see MemoryStore.
+type MemoryRefreshTokenRepository struct {
+	store *MemoryStore
+	repoOptions
+}
+
+func copyRefreshToken(token domain.RefreshToken) *domain.RefreshToken {
+	if token.RevokedAt != nil {
+		revokedAt := *token.RevokedAt
+		token.RevokedAt = &revokedAt
+	}
+	return &token
+}
+
+func (r *MemoryRefreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) (err error) {
+	ctx, finish := r.startOp(ctx, OpRefreshTokenCreate)
+	defer func() { finish(err) }()
+
+	return r.store.write(ctx, func(d *memoryData) error {
+		if _, ok := d.users[token.UserID]; !ok {
+			return domain.ErrInvalidReference
+		}
+		for _, other := range d.tokens {
+			if other.TokenHash == token.TokenHash {
+				return domain.ErrAlreadyExists
+			}
+		}
+
+		stored := *copyRefreshToken(*token)
+		stored.ID = d.lastTokenID + 1
+		stored.CreatedAt = memoryNow()
+		d.lastTokenID = stored.ID
+		d.tokens[stored.ID] = stored
+		token.ID, token.CreatedAt = stored.ID, stored.CreatedAt
+		return nil
+	})
+}
+
+func (r *MemoryRefreshTokenRepository) GetByHash(ctx context.Context, hash string) (token *domain.RefreshToken, err error) {
+	ctx, finish := r.startOp(ctx, OpRefreshTokenGetByHash)
+	defer func() { finish(err) }()
+
+	err = r.store.read(ctx, func(d *memoryData) error {
+		for _, stored := range d.tokens {
+			if stored.TokenHash == hash {
+				token = copyRefreshToken(stored)
+				return nil
+			}
+		}
+		return domain.ErrNotFound
+	})
+	return token, err
+}
+
+func (r *MemoryRefreshTokenRepository) Revoke(ctx context.Context, id int64) (err error) {
+	ctx, finish := r.startOp(ctx, OpRefreshTokenRevoke)
+	defer func() { finish(err) }()
+
+	return r.store.write(ctx, func(d *memoryData) error {
+		token, ok := d.tokens[id]
+		if !ok || token.RevokedAt != nil {
+			return domain.ErrNotFound
+		}
+
+		now := memoryNow()
+		token.RevokedAt = &now
+		d.tokens[id] = token
+		return nil
+	})
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) (err error) {
+	ctx, finish := r.startOp(ctx, OpRefreshTokenRevokeFamily)
+	defer func() { finish(err) }()
+
+	return r.store.write(ctx, func(d *memoryData) error {
+		now := memoryNow()
+		for id, token := range d.tokens {
+			if token.FamilyID == familyID && token.RevokedAt == nil {
+				token.RevokedAt = &now
+				d.tokens[id] = token
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-crud/internal/config"
+	"go-crud/internal/domain"
+	"go-crud/internal/logging"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRefreshTokenRepository_GetByHash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+	fixedTime := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "user_id", "token_hash", "family_id", "expires_at", "revoked_at", "created_at"}
+
+	mock.ExpectQuery(`SELECT id, user_id, token_hash, family_id, expires_at, revoked_at, created_at FROM refresh_tokens WHERE token_hash = \?`).
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(1, 2, "hash", "family", fixedTime, fixedTime, fixedTime))
+
+	token, err := repo.GetByHash(context.Background(), "hash")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token.UserID != 2 || token.FamilyID != "family" || token.RevokedAt == nil {
+		t.Errorf("unexpected token: %+v", token)
+	}
+
+	mock.ExpectQuery(`SELECT .* FROM refresh_tokens WHERE token_hash = \?`).
+		WithArgs("missing").
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := repo.GetByHash(context.Background(), "missing"); err != domain.ErrNotFound {
+		t.Errorf("expected error: %v, got: %v", domain.ErrNotFound, err)
+	}
+}
+
+func TestRefreshTokenRepository_Revoke(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewRefreshTokenRepository(db)
+	subtests := []struct {
+		name        string
+		id          int64
+		expectedErr error
+		setupMock   func()
+	}{
+		{
+			name:        "revoked",
+			id:          1,
+			expectedErr: nil,
+			setupMock: func() {
+				mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\) WHERE id = \? AND revoked_at IS NULL`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:        "already revoked",
+			id:          1,
+			expectedErr: domain.ErrNotFound,
+			setupMock: func() {
+				mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\) WHERE id = \? AND revoked_at IS NULL`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			subtest.setupMock()
+
+			err := repo.Revoke(context.Background(), subtest.id)
+
+			if err != subtest.expectedErr {
+				t.Errorf("expected error: %v, got: %v", subtest.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenRepository_CreateDialects(t *testing.T) {
+	expiresAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	subtests := []struct {
+		name      string
+		dialect   dialect
+		setupMock func(mock sqlmock.Sqlmock)
+	}{
+		{
+			name:    "mysql",
+			dialect: mysqlDialect,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(regexp.QuoteMeta("VALUES (?, ?, ?, ?, NOW())")).
+					WithArgs(2, "hash", "family", expiresAt).
+					WillReturnResult(sqlmock.NewResult(7, 1))
+			},
+		},
+		{
+			name:    "postgres",
+			dialect: postgresDialect,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("VALUES ($1, $2, $3, $4, NOW()) RETURNING id")).
+					WithArgs(2, "hash", "family", expiresAt).
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+			},
+		},
+		{
+			name:    "sqlite",
+			dialect: sqliteDialect,
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(regexp.QuoteMeta("VALUES (?, ?, ?, ?, "+sqliteNow+") RETURNING id")).
+					WithArgs(2, "hash", "family", "2023-01-01 12:00:00.000").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+			},
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to open sqlmock database: %v", err)
+			}
+			defer db.Close()
+
+			subtest.setupMock(mock)
+			repo := &RefreshTokenRepository{db: db, dialect: subtest.dialect, repoOptions: newRepoOptions(nil)}
+			token := &domain.RefreshToken{UserID: 2, TokenHash: "hash", FamilyID: "family", ExpiresAt: expiresAt}
+
+			if err := repo.Create(context.Background(), token); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if token.ID != 7 {
+				t.Errorf("expected ID: %d, got: %d", 7, token.ID)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenRepository_Operations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	observer := &recordingObserver{}
+	repo := NewRefreshTokenRepository(db, WithQueryObserver(observer), WithLogger(logging.Discard()), WithQueryTimeouts(config.QueryTimeouts{
+		Default:      time.Second,
+		PerOperation: map[string]time.Duration{OpRefreshTokenRevokeFamily: 10 * time.Millisecond},
+	}))
+
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\) WHERE id = \? AND revoked_at IS NULL`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = NOW\(\) WHERE family_id = \? AND revoked_at IS NULL`).
+		WithArgs("family").
+		WillDelayFor(time.Second).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.Revoke(context.Background(), 1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = repo.RevokeFamily(context.Background(), "family")
+	if !errors.Is(err, domain.ErrTimeout) {
+		t.Fatalf("expected error: %v, got: %v", domain.ErrTimeout, err)
+	}
+
+	want := []string{OpRefreshTokenRevoke, OpRefreshTokenRevokeFamily + " " + err.Error()}
+	if fmt.Sprint(observer.operations) != fmt.Sprint(want) {
+		t.Errorf("expected operations: %v, got: %v", want, observer.operations)
+	}
+}
@@ -0,0 +1,448 @@
+// Package repotest holds the contract every domain.UserRepository
+// implementation must satisfy
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-crud/internal/domain"
+	"sync"
+	"testing"
+	"time"
+)
+
+// UserRepositoryFactory returns an empty repository. It is called once per
+// test and should register any cleanup with t.
+type UserRepositoryFactory func(t *testing.T) domain.UserRepository
+
+// TestUserRepository runs the contract tests against the repositories
+// newRepo returns.
+func TestUserRepository(t *testing.T, newRepo UserRepositoryFactory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo domain.UserRepository)
+	}{
+		{"Create", testCreate},
+		{"GetByID", testGetByID},
+		{"GetByLogin", testGetByLogin},
+		{"Uniqueness", testUniqueness},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"Purge", testPurge},
+		{"List", testList},
+		{"ConcurrentCreate", testConcurrentCreate},
+		{"ConcurrentUpdate", testConcurrentUpdate},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newRepo(t))
+		})
+	}
+}
+
+// timestampSlack allows for databases that store timestamps with second
+// precision.
+const timestampSlack = time.Second
+
+func newUser(name string) *domain.User {
+	return &domain.User{Username: name, Email: name + "@email.com", Password: "hash-" + name}
+}
+
+func mustCreate(t *testing.T, repo domain.UserRepository, name string) *domain.User {
+	t.Helper()
+	user := newUser(name)
+	if err := repo.Create(context.Background(), user); err != nil {
+		t.Fatalf("failed to create user %q: %v", name, err)
+	}
+	return user
+}
+
+func mustGet(t *testing.T, repo domain.UserRepository, id int64) *domain.User {
+	t.Helper()
+	user, err := repo.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("failed to get user %d: %v", id, err)
+	}
+	return user
+}
+
+func expectErr(t *testing.T, want, got error) {
+	t.Helper()
+	if !errors.Is(got, want) {
+		t.Errorf("expected error: %v, got: %v", want, got)
+	}
+}
+
+// expectConstraint checks that err is want, reported for field.
+func expectConstraint(t *testing.T, want error, field string, err error) {
+	t.Helper()
+	var constraintErr *domain.ConstraintError
+	if !errors.Is(err, want) || !errors.As(err, &constraintErr) || constraintErr.Field != field {
+		t.Errorf("expected error: %v on field %q, got: %v", want, field, err)
+	}
+}
+
+func testCreate(t *testing.T, repo domain.UserRepository) {
+	before := time.Now()
+	user := mustCreate(t, repo, "alice")
+	after := time.Now()
+
+	if user.ID == 0 {
+		t.Errorf("expected an id to be assigned")
+	}
+	if user.Version != 1 {
+		t.Errorf("expected version: 1, got: %d", user.Version)
+	}
+	if user.Role != domain.RoleUser {
+		t.Errorf("expected role: %q, got: %q", domain.RoleUser, user.Role)
+	}
+	if user.CreatedAt.Before(before.Add(-timestampSlack)) || user.CreatedAt.After(after.Add(timestampSlack)) {
+		t.Errorf("expected created_at between %v and %v, got: %v", before, after, user.CreatedAt)
+	}
+	if !user.UpdatedAt.Equal(user.CreatedAt) {
+		t.Errorf("expected updated_at: %v, got: %v", user.CreatedAt, user.UpdatedAt)
+	}
+
+	admin := newUser("bob")
+	admin.Role = domain.RoleAdmin
+	if err := repo.Create(context.Background(), admin); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if admin.ID == user.ID {
+		t.Errorf("expected distinct ids, got: %d twice", admin.ID)
+	}
+	if got := mustGet(t, repo, admin.ID); got.Role != domain.RoleAdmin {
+		t.Errorf("expected role: %q, got: %q", domain.RoleAdmin, got.Role)
+	}
+}
+
+func testGetByID(t *testing.T, repo domain.UserRepository) {
+	user := mustCreate(t, repo, "alice")
+
+	got := mustGet(t, repo, user.ID)
+	if got.ID != user.ID || got.Username != user.Username || got.Email != user.Email || got.Role != user.Role || got.Version != user.Version {
+		t.Errorf("expected user: %+v, got: %+v", user, got)
+	}
+	if !got.CreatedAt.Equal(user.CreatedAt) || !got.UpdatedAt.Equal(user.UpdatedAt) {
+		t.Errorf("expected timestamps: %v, %v, got: %v, %v", user.CreatedAt, user.UpdatedAt, got.CreatedAt, got.UpdatedAt)
+	}
+	if got.Password != "" {
+		t.Errorf("expected no password hash, got: %q", got.Password)
+	}
+
+	_, err := repo.GetByID(context.Background(), user.ID+1000)
+	expectErr(t, domain.ErrNotFound, err)
+}
+
+func testGetByLogin(t *testing.T, repo domain.UserRepository) {
+	user := mustCreate(t, repo, "alice")
+
+	for _, login := range []string{user.Username, user.Email} {
+		got, err := repo.GetByLogin(context.Background(), login)
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", login, err)
+		}
+		if got.ID != user.ID || got.Password != user.Password {
+			t.Errorf("expected user %d with password %q, got: %+v", user.ID, user.Password, got)
+		}
+	}
+
+	_, err := repo.GetByLogin(context.Background(), "nobody")
+	expectErr(t, domain.ErrNotFound, err)
+}
+
+func testUniqueness(t *testing.T, repo domain.UserRepository) {
+	ctx := context.Background()
+	alice := mustCreate(t, repo, "alice")
+	bob := mustCreate(t, repo, "bob")
+
+	err := repo.Create(ctx, &domain.User{Username: "other", Email: alice.Email, Password: "hash"})
+	expectConstraint(t, domain.ErrAlreadyExists, "email", err)
+
+	err = repo.Create(ctx, &domain.User{Username: alice.Username, Email: "other@email.com", Password: "hash"})
+	expectConstraint(t, domain.ErrAlreadyExists, "username", err)
+
+	err = repo.Update(ctx, bob.ID, &domain.UserUpdate{Email: &alice.Email})
+	expectConstraint(t, domain.ErrAlreadyExists, "email", err)
+	if got := mustGet(t, repo, bob.ID); got.Email != bob.Email || got.Version != bob.Version {
+		t.Errorf("expected failed update to leave user unchanged, got: %+v", got)
+	}
+
+	// Soft-deleted users do not hold on to their email and username, but
+	// cannot be restored while someone else uses them.
+	if err := repo.Delete(ctx, alice.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mustCreate(t, repo, "alice")
+	err = repo.Restore(ctx, alice.ID)
+	expectErr(t, domain.ErrAlreadyExists, err)
+}
+
+func testUpdate(t *testing.T, repo domain.UserRepository) {
+	ctx := context.Background()
+	user := mustCreate(t, repo, "alice")
+
+	username, email, password, role := "alice2", "alice2@email.com", "new-hash", domain.RoleAdmin
+	err := repo.Update(ctx, user.ID, &domain.UserUpdate{
+		Username: &username,
+		Email:    &email,
+		Password: &password,
+		Role:     &role,
+		Version:  &user.Version,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := repo.GetByLogin(ctx, username)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Email != email || got.Password != password || got.Role != role {
+		t.Errorf("expected updated fields, got: %+v", got)
+	}
+	if got.Version != user.Version+1 {
+		t.Errorf("expected version: %d, got: %d", user.Version+1, got.Version)
+	}
+	if !got.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("expected created_at to stay %v, got: %v", user.CreatedAt, got.CreatedAt)
+	}
+	if got.UpdatedAt.Before(user.UpdatedAt) {
+		t.Errorf("expected updated_at after %v, got: %v", user.UpdatedAt, got.UpdatedAt)
+	}
+
+	subtests := []struct {
+		name    string
+		id      int64
+		upd     *domain.UserUpdate
+		wantErr error
+	}{
+		{name: "stale version", id: user.ID, upd: &domain.UserUpdate{Username: &username, Version: &user.Version}, wantErr: domain.ErrConflict},
+		{name: "stale version only", id: user.ID, upd: &domain.UserUpdate{Version: &user.Version}, wantErr: domain.ErrConflict},
+		{name: "current version only", id: user.ID, upd: &domain.UserUpdate{Version: &got.Version}},
+		{name: "nothing to update", id: user.ID, upd: &domain.UserUpdate{}},
+		{name: "missing user", id: user.ID + 1000, upd: &domain.UserUpdate{Username: &username}, wantErr: domain.ErrNotFound},
+		{name: "missing user with version", id: user.ID + 1000, upd: &domain.UserUpdate{Username: &username, Version: &user.Version}, wantErr: domain.ErrNotFound},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			expectErr(t, subtest.wantErr, repo.Update(ctx, subtest.id, subtest.upd))
+		})
+	}
+
+	if after := mustGet(t, repo, user.ID); after.Version != got.Version {
+		t.Errorf("expected no-op updates to keep version %d, got: %d", got.Version, after.Version)
+	}
+}
+
+func testDelete(t *testing.T, repo domain.UserRepository) {
+	ctx := context.Background()
+	user := mustCreate(t, repo, "alice")
+
+	if err := repo.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := repo.GetByID(ctx, user.ID)
+	expectErr(t, domain.ErrNotFound, err)
+	_, err = repo.GetByLogin(ctx, user.Username)
+	expectErr(t, domain.ErrNotFound, err)
+	expectErr(t, domain.ErrNotFound, repo.Delete(ctx, user.ID))
+	expectErr(t, domain.ErrNotFound, repo.Update(ctx, user.ID, &domain.UserUpdate{Username: &user.Username}))
+
+	if err := repo.Restore(ctx, user.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	restored := mustGet(t, repo, user.ID)
+	if restored.Version <= user.Version {
+		t.Errorf("expected version above %d after delete and restore, got: %d", user.Version, restored.Version)
+	}
+	expectErr(t, domain.ErrNotFound, repo.Restore(ctx, user.ID))
+
+	if err := repo.HardDelete(ctx, user.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expectErr(t, domain.ErrNotFound, repo.Restore(ctx, user.ID))
+	expectErr(t, domain.ErrNotFound, repo.HardDelete(ctx, user.ID))
+
+	// Hard deletes also apply to soft-deleted users.
+	other := mustCreate(t, repo, "bob")
+	if err := repo.Delete(ctx, other.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := repo.HardDelete(ctx, other.ID); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func testPurge(t *testing.T, repo domain.UserRepository) {
+	ctx := context.Background()
+	live := mustCreate(t, repo, "alice")
+	deleted := mustCreate(t, repo, "bob")
+	if err := repo.Delete(ctx, deleted.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	purged, err := repo.Purge(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != 0 {
+		t.Errorf("expected nothing deleted before the cutoff, purged: %d", purged)
+	}
+
+	purged, err = repo.Purge(ctx, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("expected 1 purged user, got: %d", purged)
+	}
+	expectErr(t, domain.ErrNotFound, repo.Restore(ctx, deleted.ID))
+	mustGet(t, repo, live.ID)
+}
+
+func testList(t *testing.T, repo domain.UserRepository) {
+	ctx := context.Background()
+	names := []string{"carol", "alice", "dave", "bob", "erin"}
+	for _, name := range names {
+		mustCreate(t, repo, name)
+	}
+	deleted := mustCreate(t, repo, "mallory")
+	if err := repo.Delete(ctx, deleted.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	subtests := []struct {
+		name   string
+		params domain.UserListParams
+		want   []string
+	}{
+		{name: "by id", params: domain.UserListParams{}, want: names},
+		{name: "by username", params: domain.UserListParams{SortBy: domain.SortByUsername}, want: []string{"alice", "bob", "carol", "dave", "erin"}},
+		{name: "by email descending", params: domain.UserListParams{SortBy: domain.SortByEmail, SortDesc: true}, want: []string{"erin", "dave", "carol", "bob", "alice"}},
+		{name: "by created_at", params: domain.UserListParams{SortBy: domain.SortByCreatedAt}, want: names},
+		{name: "username prefix", params: domain.UserListParams{Filter: domain.UserFilter{UsernamePrefix: "da"}}, want: []string{"dave"}},
+		{name: "email prefix", params: domain.UserListParams{Filter: domain.UserFilter{EmailPrefix: "b"}}, want: []string{"bob"}},
+		{name: "deleted", params: domain.UserListParams{Filter: domain.UserFilter{Deleted: true}}, want: []string{"mallory"}},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			// Walk the pages two users at a time.
+			params := subtest.params
+			params.Limit = 2
+			params.IncludeTotal = true
+			got := []string{}
+			for range len(names) {
+				list, err := repo.List(ctx, params)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if list.Total == nil || *list.Total != int64(len(subtest.want)) {
+					t.Errorf("expected total: %d, got: %v", len(subtest.want), list.Total)
+				}
+				for _, user := range list.Users {
+					if user.Password != "" {
+						t.Errorf("expected no password hash for %q", user.Username)
+					}
+					got = append(got, user.Username)
+				}
+				if list.NextCursor == nil {
+					break
+				}
+				params.Cursor = list.NextCursor
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(subtest.want) {
+				t.Errorf("expected users: %v, got: %v", subtest.want, got)
+			}
+		})
+	}
+
+	t.Run("offset", func(t *testing.T) {
+		list, err := repo.List(ctx, domain.UserListParams{Limit: 2, Offset: 4})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(list.Users) != 1 || list.Users[0].Username != "erin" || list.NextCursor != nil {
+			t.Errorf("expected only erin on the last page, got: %+v", list)
+		}
+	})
+
+	t.Run("mismatched cursor", func(t *testing.T) {
+		cursor := &domain.UserCursor{SortBy: domain.SortByUsername, Value: "bob", ID: 1}
+		_, err := repo.List(ctx, domain.UserListParams{Limit: 2, Cursor: cursor})
+		expectErr(t, domain.ErrInvalidCursor, err)
+	})
+}
+
+// concurrency is the number of goroutines the concurrency tests start.
+const concurrency = 10
+
+func testConcurrentCreate(t *testing.T, repo domain.UserRepository) {
+	errs := make([]error, concurrency)
+	var wg sync.WaitGroup
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = repo.Create(context.Background(), &domain.User{
+				Username: fmt.Sprintf("user%d", i),
+				Email:    "same@email.com",
+				Password: "hash",
+			})
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, domain.ErrAlreadyExists):
+			t.Errorf("expected error: %v, got: %v", domain.ErrAlreadyExists, err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("expected exactly 1 created user, got: %d", created)
+	}
+}
+
+func testConcurrentUpdate(t *testing.T, repo domain.UserRepository) {
+	user := mustCreate(t, repo, "alice")
+
+	errs := make([]error, concurrency)
+	var wg sync.WaitGroup
+	for i := range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			username := fmt.Sprintf("alice%d", i)
+			errs[i] = repo.Update(context.Background(), user.ID, &domain.UserUpdate{
+				Username: &username,
+				Version:  &user.Version,
+			})
+		}()
+	}
+	wg.Wait()
+
+	updated := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			updated++
+		case !errors.Is(err, domain.ErrConflict):
+			t.Errorf("expected error: %v, got: %v", domain.ErrConflict, err)
+		}
+	}
+	if updated != 1 {
+		t.Errorf("expected exactly 1 update to win, got: %d", updated)
+	}
+	if got := mustGet(t, repo, user.ID); got.Version != user.Version+1 {
+		t.Errorf("expected version: %d, got: %d", user.Version+1, got.Version)
+	}
+}
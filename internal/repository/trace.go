@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-crud/internal/domain"
+	"go-crud/internal/tracing"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedDB runs statements on db, each in a client span that is a child of
+// the span in the statement's context.
+type tracedDB struct {
+	db      DBTX
+	dialect dialect
+}
+
+func (t *tracedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+
+	result, err := t.db.ExecContext(ctx, query, args...)
+	if err == nil {
+		if rows, rowsErr := result.RowsAffected(); rowsErr == nil {
+			span.SetAttributes(attribute.Int64("db.response.rows_affected", rows))
+		}
+	}
+	t.finish(ctx, span, err)
+	return result, err
+}
+
+func (t *tracedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	t.finish(ctx, span, err)
+	return rows, err
+}
+
+// QueryRowContext ends the span before the row is scanned, so a missing
+// row does not show up in it.
+func (t *tracedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+
+	row := t.db.QueryRowContext(ctx, query, args...)
+	t.finish(ctx, span, row.Err())
+	return row
+}
+
+func (t *tracedDB) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	query = sanitizeQuery(query)
+	operation, table := queryTarget(query)
+
+	name := operation
+	attrs := []attribute.KeyValue{t.dialect.system, semconv.DBQueryText(query), semconv.DBOperationName(operation)}
+	if table != "" {
+		name += " " + table
+		attrs = append(attrs, semconv.DBCollectionName(table))
+	}
+	return tracing.Tracer().Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+// finish records err on span the way the repositories report it.
+func (t *tracedDB) finish(ctx context.Context, span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	err = t.dialect.queryError(ctx, err)
+	span.SetAttributes(semconv.ErrorTypeKey.String(errorType(err)))
+	if !isClientError(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// errorTypes are the domain errors spans are labeled with, so the
+// error.type attribute stays low in cardinality.
+var errorTypes = []struct {
+	err  error
+	name string
+}{
+	{domain.ErrNotFound, "not_found"},
+	{domain.ErrAlreadyExists, "already_exists"},
+	{domain.ErrValueTooLong, "value_too_long"},
+	{domain.ErrRequiredValue, "required_value"},
+	{domain.ErrReferenced, "referenced"},
+	{domain.ErrInvalidReference, "invalid_reference"},
+	{domain.ErrTimeout, "timeout"},
+	{domain.ErrCanceled, "canceled"},
+	{domain.ErrDeadlock, "deadlock"},
+	{domain.ErrLockTimeout, "lock_timeout"},
+	{domain.ErrUnavailable, "unavailable"},
+	{domain.ErrReadOnly, "read_only"},
+}
+
+func errorType(err error) string {
+	for _, t := range errorTypes {
+		if errors.Is(err, t.err) {
+			return t.name
+		}
+	}
+	return "_OTHER"
+}
+
+var (
+	// stringLiteralPattern matches SQL string literals, including
+	// doubled quotes inside them.
+	stringLiteralPattern = regexp.MustCompile(`'(?:[^']|'')*'`)
+	// numberLiteralPattern leaves $1 placeholders and digits inside
+	// identifiers alone.
+	numberLiteralPattern = regexp.MustCompile(`([^\w$.]|^)\d+(?:\.\d+)?\b`)
+	queryTargetPattern   = regexp.MustCompile(`(?i)\b(?:FROM|INTO|UPDATE)\s+(\w+)`)
+)
+
+// sanitizeQuery collapses whitespace and replaces literals with ?. The
+// repositories bind every value as an argument, but a literal could still
+// end up in a query.
+func sanitizeQuery(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	query = stringLiteralPattern.ReplaceAllString(query, "?")
+	return numberLiteralPattern.ReplaceAllString(query, "${1}?")
+}
+
+// queryTarget returns the SQL verb and the first table of query.
+func queryTarget(query string) (operation, table string) {
+	operation, _, _ = strings.Cut(query, " ")
+	if m := queryTargetPattern.FindStringSubmatch(query); m != nil {
+		table = m[1]
+	}
+	return strings.ToUpper(operation), table
+}
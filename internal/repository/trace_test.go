@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider that keeps every ended span, and
+// restores the previous provider when the test ends.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracedDB(t *testing.T) {
+	recorder := recordSpans(t)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	traced := &tracedDB{db: db, dialect: mysqlDialect}
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+
+	subtests := []struct {
+		name          string
+		setupMock     func()
+		run           func() error
+		wantName      string
+		wantAttrs     map[string]string
+		wantRows      int64
+		wantErrorType string
+		wantStatus    codes.Code
+	}{
+		{
+			name: "Exec records the affected rows",
+			setupMock: func() {
+				mock.ExpectExec(`UPDATE users`).WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+			run: func() error {
+				_, err := traced.ExecContext(ctx, "UPDATE users\n\tSET role = 'admin' WHERE id = ?", 1)
+				return err
+			},
+			wantName: "UPDATE users",
+			wantAttrs: map[string]string{
+				"db.system.name":     "mysql",
+				"db.query.text":      "UPDATE users SET role = ? WHERE id = ?",
+				"db.operation.name":  "UPDATE",
+				"db.collection.name": "users",
+			},
+			wantRows:   3,
+			wantStatus: codes.Unset,
+		},
+		{
+			name: "constraint violations are not span errors",
+			setupMock: func() {
+				mock.ExpectExec(`INSERT INTO users`).
+					WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'test@email.com-1' for key 'users.users_email_unique'"})
+			},
+			run: func() error {
+				_, err := traced.ExecContext(ctx, "INSERT INTO users (email) VALUES (?)", "test@email.com")
+				return err
+			},
+			wantName:      "INSERT users",
+			wantErrorType: "already_exists",
+			wantStatus:    codes.Unset,
+		},
+		{
+			name: "connection errors are span errors",
+			setupMock: func() {
+				mock.ExpectQuery(`SELECT id FROM users`).WillReturnError(sql.ErrConnDone)
+			},
+			run: func() error {
+				_, err := traced.QueryContext(ctx, "SELECT id FROM users LIMIT 10")
+				return err
+			},
+			wantName:      "SELECT users",
+			wantAttrs:     map[string]string{"db.query.text": "SELECT id FROM users LIMIT ?"},
+			wantErrorType: "unavailable",
+			wantStatus:    codes.Error,
+		},
+		{
+			name: "canceled queries are not span errors",
+			setupMock: func() {
+				mock.ExpectQuery(`SELECT id FROM users`).WillReturnError(context.Canceled)
+			},
+			run: func() error {
+				_, err := traced.QueryContext(ctx, "SELECT id FROM users")
+				return err
+			},
+			wantName:      "SELECT users",
+			wantErrorType: "canceled",
+			wantStatus:    codes.Unset,
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			recorder.Reset()
+			subtest.setupMock()
+			subtest.run()
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unfulfilled expectations: %v", err)
+			}
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("expected 1 span, got: %d", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != subtest.wantName {
+				t.Errorf("expected span name: %q, got: %q", subtest.wantName, span.Name())
+			}
+			if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+				t.Errorf("expected the span to be a child of the context's span")
+			}
+
+			attrs := spanAttributes(span)
+			for key, want := range subtest.wantAttrs {
+				if got := attrs[attribute.Key(key)].Emit(); got != want {
+					t.Errorf("expected %s: %q, got: %q", key, want, got)
+				}
+			}
+			if got := attrs["db.response.rows_affected"].AsInt64(); got != subtest.wantRows {
+				t.Errorf("expected rows affected: %d, got: %d", subtest.wantRows, got)
+			}
+			if got := attrs["error.type"].AsString(); got != subtest.wantErrorType {
+				t.Errorf("expected error.type: %q, got: %q", subtest.wantErrorType, got)
+			}
+			if got := span.Status().Code; got != subtest.wantStatus {
+				t.Errorf("expected status: %v, got: %v", subtest.wantStatus, got)
+			}
+		})
+	}
+}
+
+func TestSanitizeQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM users WHERE id = ?", "SELECT * FROM users WHERE id = ?"},
+		{"SELECT *\n\tFROM users\n\tWHERE id = $1", "SELECT * FROM users WHERE id = $1"},
+		{"UPDATE users SET name = 'O''Brien', version = 2 WHERE id = 10", "UPDATE users SET name = ?, version = ? WHERE id = ?"},
+		{"SELECT 1.5, t1.col FROM t1", "SELECT ?, t1.col FROM t1"},
+	}
+
+	for _, test := range tests {
+		if got := sanitizeQuery(test.query); got != test.want {
+			t.Errorf("sanitizeQuery(%q): expected %q, got: %q", test.query, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go-crud/internal/config"
+	"go-crud/internal/domain"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// DBTX is the part of *sql.DB and *sql.Tx the repositories use, so the
+// same repository code runs on its own or inside a transaction.
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Store builds the repositories of one database driver, either on the
+// connection pool or on a transaction.
+type Store struct {
+	dialect dialect
+	opts    []Option
+}
+
+func NewStore(driver string, opts ...Option) (*Store, error) {
+	switch driver {
+	case config.DriverMySQL:
+		return &Store{dialect: mysqlDialect, opts: opts}, nil
+	case config.DriverPostgres:
+		return &Store{dialect: postgresDialect, opts: opts}, nil
+	case config.DriverSQLite:
+		return &Store{dialect: sqliteDialect, opts: opts}, nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// Repositories returns every repository, running its queries on q. Each
+// statement is traced, see tracedDB.
+func (s *Store) Repositories(q DBTX) domain.Repositories {
+	q = &tracedDB{db: q, dialect: s.dialect}
+	return domain.Repositories{
+		Users:         &UserRepository{db: q, dialect: s.dialect, repoOptions: newRepoOptions(s.opts)},
+		RefreshTokens: &RefreshTokenRepository{db: q, dialect: s.dialect, repoOptions: newRepoOptions(s.opts)},
+	}
+}
+
+const (
+	defaultTxAttempts = 3
+	defaultTxBackoff  = 50 * time.Millisecond
+)
+
+// TxManager implements domain.TxManager on top of a *sql.DB. Nested units
+// of work run in savepoints of the outermost transaction, and the
+// outermost one is retried when the database aborts it with a deadlock or
+// a lock wait timeout.
+type TxManager struct {
+	db          *sql.DB
+	store       *Store
+	maxAttempts int
+	backoff     time.Duration
+	logger      *slog.Logger
+}
+
+type TxOption func(*TxManager)
+
+// WithTxRetries sets how many times a unit of work is attempted in total
+// and the base delay between attempts, which grows with every attempt.
+func WithTxRetries(maxAttempts int, backoff time.Duration) TxOption {
+	return func(m *TxManager) {
+		m.maxAttempts = max(maxAttempts, 1)
+		m.backoff = backoff
+	}
+}
+
+func WithTxLogger(logger *slog.Logger) TxOption {
+	return func(m *TxManager) {
+		m.logger = logger
+	}
+}
+
+// NewTxManager returns a TxManager that hands the repositories of store,
+// bound to the transaction, to the units of work.
+func NewTxManager(db *sql.DB, store *Store, opts ...TxOption) *TxManager {
+	m := &TxManager{
+		db:          db,
+		store:       store,
+		maxAttempts: defaultTxAttempts,
+		backoff:     defaultTxBackoff,
+		logger:      slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+type txKey struct{}
+
+// txState is the running transaction, stored in the context passed to
+// units of work.
+type txState struct {
+	tx         *sql.Tx
+	repos      domain.Repositories
+	savepoints int
+}
+
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context, repos domain.Repositories) error) error {
+	if state, ok := ctx.Value(txKey{}).(*txState); ok {
+		return m.withinSavepoint(ctx, state, fn)
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := m.run(ctx, fn)
+		if err == nil || attempt >= m.maxAttempts || !isRetryableTxError(err) {
+			return err
+		}
+
+		m.logger.WarnContext(ctx, "retrying transaction", "attempt", attempt, "error", err)
+		// Jitter keeps transactions that deadlocked on each other from
+		// colliding again.
+		delay := m.backoff * time.Duration(attempt)
+		delay += rand.N(delay/2 + 1)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+func (m *TxManager) run(ctx context.Context, fn func(ctx context.Context, repos domain.Repositories) error) (err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return m.store.dialect.queryError(ctx, err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			m.rollback(ctx, tx)
+			panic(p)
+		}
+	}()
+
+	state := &txState{tx: tx, repos: m.store.Repositories(tx)}
+	if err := fn(context.WithValue(ctx, txKey{}, state), state.repos); err != nil {
+		m.rollback(ctx, tx)
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return m.store.dialect.queryError(ctx, err)
+	}
+	return nil
+}
+
+func (m *TxManager) rollback(ctx context.Context, tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		m.logger.ErrorContext(ctx, "failed to roll back transaction", "error", err)
+	}
+}
+
+// withinSavepoint runs a nested unit of work. A panic is left to the
+// outermost unit of work, which rolls back the whole transaction.
+func (m *TxManager) withinSavepoint(ctx context.Context, state *txState, fn func(ctx context.Context, repos domain.Repositories) error) error {
+	state.savepoints++
+	savepoint := fmt.Sprintf("sp_%d", state.savepoints)
+
+	queryError := m.store.dialect.queryError
+	if _, err := state.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return queryError(ctx, err)
+	}
+
+	if err := fn(ctx, state.repos); err != nil {
+		if _, rbErr := state.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rbErr != nil {
+			return errors.Join(err, queryError(ctx, rbErr))
+		}
+		return err
+	}
+
+	if _, err := state.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+		return queryError(ctx, err)
+	}
+	return nil
+}
+
+// inTx runs fn in a transaction of its own when q is a *sql.DB. When q is
+// already a transaction fn simply runs on it.
+func inTx(ctx context.Context, q DBTX, fn func(q DBTX) error) error {
+	if traced, ok := q.(*tracedDB); ok {
+		return inTx(ctx, traced.db, func(q DBTX) error {
+			return fn(&tracedDB{db: q, dialect: traced.dialect})
+		})
+	}
+
+	db, ok := q.(*sql.DB)
+	if !ok {
+		return fn(q)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return resolveQueryError(ctx, err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return resolveQueryError(ctx, err)
+	}
+	return nil
+}
+
+// isRetryableTxError reports whether the database aborted the transaction
+// with a deadlock or a lock wait timeout, after which running it again may
+// succeed.
+func isRetryableTxError(err error) bool {
+	return errors.Is(err, domain.ErrDeadlock) || errors.Is(err, domain.ErrLockTimeout)
+}
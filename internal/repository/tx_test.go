@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-crud/internal/config"
+	"go-crud/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+)
+
+func newTestTxManager(t *testing.T) (*TxManager, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, err := NewStore(config.DriverMySQL)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+	return NewTxManager(db, store, WithTxRetries(3, time.Millisecond)), mock
+}
+
+func TestTxManager_WithinTx(t *testing.T) {
+	errBoom := errors.New("boom")
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+
+	subtests := []struct {
+		name         string
+		setupMock    func(mock sqlmock.Sqlmock)
+		fn           func(attempt int) error
+		wantErr      error
+		wantAttempts int
+	}{
+		{
+			name: "commit on success",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			fn:           func(int) error { return nil },
+			wantAttempts: 1,
+		},
+		{
+			name: "rollback on error",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			fn:           func(int) error { return errBoom },
+			wantErr:      errBoom,
+			wantAttempts: 1,
+		},
+		{
+			name: "retry on deadlock",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+				mock.ExpectBegin()
+				mock.ExpectCommit()
+			},
+			fn: func(attempt int) error {
+				if attempt == 1 {
+					return resolveSQLError(deadlock)
+				}
+				return nil
+			},
+			wantAttempts: 2,
+		},
+		{
+			name: "retry on lock wait timeout until attempts run out",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				for range 3 {
+					mock.ExpectBegin()
+					mock.ExpectRollback()
+				}
+			},
+			fn: func(int) error {
+				return resolveSQLError(&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"})
+			},
+			wantErr:      &mysql.MySQLError{Number: 1205},
+			wantAttempts: 3,
+		},
+		{
+			name: "no retry on other errors",
+			setupMock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectRollback()
+			},
+			fn:           func(int) error { return domain.ErrNotFound },
+			wantErr:      domain.ErrNotFound,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			txm, mock := newTestTxManager(t)
+			subtest.setupMock(mock)
+
+			attempts := 0
+			err := txm.WithinTx(context.Background(), func(ctx context.Context, repos domain.Repositories) error {
+				attempts++
+				if repos.Users == nil || repos.RefreshTokens == nil {
+					t.Fatal("expected repositories to be set")
+				}
+				return subtest.fn(attempts)
+			})
+
+			var wantMySQLErr *mysql.MySQLError
+			if errors.As(subtest.wantErr, &wantMySQLErr) {
+				var mysqlErr *mysql.MySQLError
+				if !errors.As(err, &mysqlErr) || mysqlErr.Number != wantMySQLErr.Number {
+					t.Errorf("expected error: %v, got: %v", subtest.wantErr, err)
+				}
+			} else if !errors.Is(err, subtest.wantErr) {
+				t.Errorf("expected error: %v, got: %v", subtest.wantErr, err)
+			}
+			if attempts != subtest.wantAttempts {
+				t.Errorf("expected attempts: %d, got: %d", subtest.wantAttempts, attempts)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestTxManager_Savepoints(t *testing.T) {
+	txm, mock := newTestTxManager(t)
+	errBoom := errors.New("boom")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sp_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT sp_2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := txm.WithinTx(context.Background(), func(ctx context.Context, _ domain.Repositories) error {
+		err := txm.WithinTx(ctx, func(context.Context, domain.Repositories) error { return errBoom })
+		if !errors.Is(err, errBoom) {
+			t.Errorf("expected error: %v, got: %v", errBoom, err)
+		}
+		return txm.WithinTx(ctx, func(context.Context, domain.Repositories) error { return nil })
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestTxManager_RollbackOnPanic(t *testing.T) {
+	txm, mock := newTestTxManager(t)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	defer func() {
+		if p := recover(); p != "boom" {
+			t.Errorf("expected panic: boom, got: %v", p)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	}()
+
+	_ = txm.WithinTx(context.Background(), func(context.Context, domain.Repositories) error {
+		panic("boom")
+	})
+}
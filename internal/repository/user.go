@@ -2,115 +2,335 @@
 package repository
 
 import (
-	"database/sql"
+	"context"
+	"errors"
+	"fmt"
+	"go-crud/internal/config"
 	"go-crud/internal/domain"
+	"log/slog"
 	"strings"
+	"time"
 )
 
+// Operation names used to look up per-operation query timeouts and to
+// label the recorded query durations.
+const (
+	OpCreate     = "create"
+	OpGetByID    = "get_by_id"
+	OpGetByLogin = "get_by_login"
+	OpUpdate     = "update"
+	OpDelete     = "delete"
+	OpRestore    = "restore"
+	OpHardDelete = "hard_delete"
+	OpPurge      = "purge"
+	OpList       = "list"
+
+	OpRefreshTokenCreate       = "refresh_token_create"
+	OpRefreshTokenGetByHash    = "refresh_token_get_by_hash"
+	OpRefreshTokenRevoke       = "refresh_token_revoke"
+	OpRefreshTokenRevokeFamily = "refresh_token_revoke_family"
+)
+
+// UserRepository is the SQL implementation of domain.UserRepository. Its
+// dialect adapts the queries to MySQL, PostgreSQL or SQLite.
 type UserRepository struct {
-	db *sql.DB
+	db      DBTX
+	dialect dialect
+	repoOptions
 }
 
-func NewUserRepository(db *sql.DB) domain.UserRepository {
-	return &UserRepository{db: db}
+// repoOptions are the settings shared by the repository
+// implementations.
+type repoOptions struct {
+	timeouts config.QueryTimeouts
+	logger   *slog.Logger
+	observer QueryObserver
 }
 
-func (r *UserRepository) Create(user *domain.User) error {
-	query := `
-	INSERT INTO users (username, email, password, created_at, updated_at)
-	VALUES (?, ?, ?, NOW(), NOW())
-	`
+type Option func(*repoOptions)
 
-	result, err := r.db.Exec(query, user.Username, user.Email, user.Password)
-	if err != nil {
-		return resolveSQLError(err)
+// QueryObserver is told about every finished repository operation, e.g.
+// to record its duration.
+type QueryObserver interface {
+	ObserveQuery(operation string, duration time.Duration, err error)
+}
+
+func WithQueryTimeouts(timeouts config.QueryTimeouts) Option {
+	return func(o *repoOptions) {
+		o.timeouts = timeouts
 	}
+}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return resolveSQLError(err)
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *repoOptions) {
+		o.logger = logger
+	}
+}
+
+func WithQueryObserver(observer QueryObserver) Option {
+	return func(o *repoOptions) {
+		o.observer = observer
+	}
+}
+
+func newRepoOptions(opts []Option) repoOptions {
+	o := repoOptions{logger: slog.Default()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// NewUserRepository returns the MySQL user repository.
+func NewUserRepository(db DBTX, opts ...Option) domain.UserRepository {
+	return &UserRepository{db: db, dialect: mysqlDialect, repoOptions: newRepoOptions(opts)}
+}
+
+func NewPostgresUserRepository(db DBTX, opts ...Option) domain.UserRepository {
+	return &UserRepository{db: db, dialect: postgresDialect, repoOptions: newRepoOptions(opts)}
+}
+
+func NewSQLiteUserRepository(db DBTX, opts ...Option) domain.UserRepository {
+	return &UserRepository{db: db, dialect: sqliteDialect, repoOptions: newRepoOptions(opts)}
+}
+
+// startOp bounds ctx by the configured timeout of the operation. The
+// returned function must be called with the operation's result once it
+// has finished.
+func (r repoOptions) startOp(ctx context.Context, operation string) (context.Context, func(error)) {
+	start := time.Now()
+
+	var cancel context.CancelFunc
+	if d := r.timeouts.For(operation); d > 0 {
+		ctx, cancel = context.WithTimeout(ctx, d)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	return ctx, func(err error) {
+		cancel()
+		if r.observer != nil {
+			r.observer.ObserveQuery(operation, time.Since(start), err)
+		}
+		logOp(ctx, r.logger, operation, start, err)
+	}
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *domain.User) (err error) {
+	ctx, finish := r.startOp(ctx, OpCreate)
+	defer func() { finish(err) }()
+
+	if user.Role == "" {
+		user.Role = domain.RoleUser
+	}
+
+	args := &queryArgs{dialect: r.dialect}
+	query := `
+	INSERT INTO users (username, email, password, role, created_at, updated_at)
+	VALUES (` + args.add(user.Username) + `, ` + args.add(user.Email) + `, ` + args.add(user.Password) + `, ` +
+		args.add(user.Role) + `, ` + r.dialect.now + `, ` + r.dialect.now + `)`
+
+	if r.dialect.returning {
+		row := r.db.QueryRowContext(ctx, query+" RETURNING id, version, created_at, updated_at", args.values...)
+		if err := row.Scan(&user.ID, &user.Version, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return r.dialect.queryError(ctx, err)
+		}
+		return nil
 	}
 
-	user.ID = id
+	// The insert and the read of the generated columns must see the same
+	// row, so they share a transaction.
+	return inTx(ctx, r.db, func(q DBTX) error {
+		result, err := q.ExecContext(ctx, query, args.values...)
+		if err != nil {
+			return r.dialect.queryError(ctx, err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return r.dialect.queryError(ctx, err)
+		}
+
+		idArgs := &queryArgs{dialect: r.dialect}
+		row := q.QueryRowContext(ctx, "SELECT version, created_at, updated_at FROM users WHERE id = "+idArgs.add(id), idArgs.values...)
+		if err := row.Scan(&user.Version, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return r.dialect.queryError(ctx, err)
+		}
+		user.ID = id
+		return nil
+	})
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (_ *domain.User, err error) {
+	ctx, finish := r.startOp(ctx, OpGetByID)
+	defer func() { finish(err) }()
+
+	args := &queryArgs{dialect: r.dialect}
+	query := `
+	SELECT id, username, email, role, version, created_at, updated_at FROM users
+	WHERE id = ` + args.add(id) + ` AND deleted_at IS NULL`
+
+	row := r.db.QueryRowContext(ctx, query, args.values...)
 
-	row := r.db.QueryRow("SELECT created_at, updated_at FROM users WHERE id = ?", user.ID)
-	err = row.Scan(&user.CreatedAt, &user.UpdatedAt)
+	var user domain.User
+	err = row.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.Version, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		return resolveSQLError(err)
+		return nil, r.dialect.queryError(ctx, err)
 	}
-	return nil
+
+	return &user, nil
 }
 
-func (r *UserRepository) GetByID(id int64) (*domain.User, error) {
+func (r *UserRepository) GetByLogin(ctx context.Context, login string) (_ *domain.User, err error) {
+	ctx, finish := r.startOp(ctx, OpGetByLogin)
+	defer func() { finish(err) }()
+
+	args := &queryArgs{dialect: r.dialect}
 	query := `
-	SELECT id, username, email, created_at, updated_at FROM users
-	WHERE id = ?`
+	SELECT id, username, email, password, role, version, created_at, updated_at FROM users
+	WHERE (username = ` + args.add(login) + ` OR email = ` + args.add(login) + `) AND deleted_at IS NULL
+	LIMIT 1`
 
-	row := r.db.QueryRow(query, id)
+	row := r.db.QueryRowContext(ctx, query, args.values...)
 
 	var user domain.User
-	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	err = row.Scan(&user.ID, &user.Username, &user.Email, &user.Password, &user.Role, &user.Version, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
-		return nil, resolveSQLError(err)
+		return nil, r.dialect.queryError(ctx, err)
 	}
 
 	return &user, nil
 }
 
-func (r *UserRepository) Update(id int64, upd *domain.UserUpdate) error {
+func (r *UserRepository) Update(ctx context.Context, id int64, upd *domain.UserUpdate) (err error) {
+	args := &queryArgs{dialect: r.dialect}
 	setClauses := []string{}
-	args := []any{}
 
 	if upd.Username != nil {
-		setClauses = append(setClauses, "username = ?")
-		args = append(args, *upd.Username)
+		setClauses = append(setClauses, "username = "+args.add(*upd.Username))
 	}
-
 	if upd.Email != nil {
-		setClauses = append(setClauses, "email = ?")
-		args = append(args, *upd.Email)
+		setClauses = append(setClauses, "email = "+args.add(*upd.Email))
 	}
-
 	if upd.Password != nil {
-		setClauses = append(setClauses, "password = ?")
-		args = append(args, *upd.Password)
+		setClauses = append(setClauses, "password = "+args.add(*upd.Password))
+	}
+	if upd.Role != nil {
+		setClauses = append(setClauses, "role = "+args.add(*upd.Role))
 	}
 
-	if len(setClauses) == 0 {
+	if len(setClauses) == 0 && upd.Version == nil {
 		return nil
 	}
 
-	setClauses = append(setClauses, "updated_at = NOW()")
-	query := "UPDATE users SET " + strings.Join(setClauses, ", ") + " WHERE id = ?"
-	args = append(args, id)
+	ctx, finish := r.startOp(ctx, OpUpdate)
+	defer func() { finish(err) }()
 
-	result, err := r.db.Exec(query, args...)
-	if err != nil {
-		return resolveSQLError(err)
+	if len(setClauses) == 0 {
+		return r.checkVersion(ctx, id, *upd.Version)
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return resolveSQLError(err)
+	setClauses = append(setClauses, "updated_at = "+r.dialect.now, "version = version + 1")
+	query := "UPDATE users SET " + strings.Join(setClauses, ", ") + " WHERE id = " + args.add(id) + " AND deleted_at IS NULL"
+	if upd.Version != nil {
+		query += " AND version = " + args.add(*upd.Version)
 	}
 
-	if rowsAffected == 0 {
-		return domain.ErrNotFound
+	err = r.execOne(ctx, query, args.values...)
+	if errors.Is(err, domain.ErrNotFound) && upd.Version != nil {
+		return r.checkVersion(ctx, id, *upd.Version)
+	}
+	return err
+}
+
+// checkVersion tells apart the reasons a conditional update matched no
+// row: the user does not exist, or its version has moved on.
+func (r *UserRepository) checkVersion(ctx context.Context, id, expected int64) error {
+	args := &queryArgs{dialect: r.dialect}
+	query := "SELECT version FROM users WHERE id = " + args.add(id) + " AND deleted_at IS NULL"
+
+	var version int64
+	if err := r.db.QueryRowContext(ctx, query, args.values...).Scan(&version); err != nil {
+		return r.dialect.queryError(ctx, err)
+	}
+	if version != expected {
+		return domain.ErrConflict
 	}
 	return nil
 }
 
-func (r *UserRepository) Delete(id int64) error {
-	query := "DELETE FROM users WHERE id = ?"
+func (r *UserRepository) Delete(ctx context.Context, id int64) (err error) {
+	ctx, finish := r.startOp(ctx, OpDelete)
+	defer func() { finish(err) }()
+
+	args := &queryArgs{dialect: r.dialect}
+	query := "UPDATE users SET deleted_at = " + r.dialect.now + ", version = version + 1 WHERE id = " + args.add(id) + " AND deleted_at IS NULL"
+
+	return r.execOne(ctx, query, args.values...)
+}
+
+func (r *UserRepository) Restore(ctx context.Context, id int64) (err error) {
+	ctx, finish := r.startOp(ctx, OpRestore)
+	defer func() { finish(err) }()
 
-	result, err := r.db.Exec(query, id)
+	args := &queryArgs{dialect: r.dialect}
+	query := "UPDATE users SET deleted_at = NULL, version = version + 1 WHERE id = " + args.add(id) + " AND deleted_at IS NOT NULL"
+
+	return r.execOne(ctx, query, args.values...)
+}
+
+func (r *UserRepository) HardDelete(ctx context.Context, id int64) (err error) {
+	ctx, finish := r.startOp(ctx, OpHardDelete)
+	defer func() { finish(err) }()
+
+	args := &queryArgs{dialect: r.dialect}
+	return r.execOne(ctx, "DELETE FROM users WHERE id = "+args.add(id), args.values...)
+}
+
+// purgeBatchSize bounds the rows a single purge statement removes, so the
+// purge never holds locks on a large part of the table at once.
+const purgeBatchSize = 1000
+
+func (r *UserRepository) Purge(ctx context.Context, deletedBefore time.Time) (purged int64, err error) {
+	ctx, finish := r.startOp(ctx, OpPurge)
+	defer func() { finish(err) }()
+
+	args := &queryArgs{dialect: r.dialect}
+	batch := "deleted_at IS NOT NULL AND deleted_at < " + args.add(deletedBefore) + " LIMIT " + args.add(purgeBatchSize)
+	query := "DELETE FROM users WHERE " + batch
+	if !r.dialect.deleteLimit {
+		query = "DELETE FROM users WHERE id IN (SELECT id FROM users WHERE " + batch + ")"
+	}
+
+	for {
+		result, err := r.db.ExecContext(ctx, query, args.values...)
+		if err != nil {
+			return purged, r.dialect.queryError(ctx, err)
+		}
+
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return purged, r.dialect.queryError(ctx, err)
+		}
+
+		purged += rowsAffected
+		if rowsAffected < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// execOne runs a statement that targets a single user, returning
+// domain.ErrNotFound when no row matched.
+func (r *UserRepository) execOne(ctx context.Context, query string, args ...any) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return resolveSQLError(err)
+		return r.dialect.queryError(ctx, err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return resolveSQLError(err)
+		return r.dialect.queryError(ctx, err)
 	}
 
 	if rowsAffected == 0 {
@@ -119,3 +339,175 @@ func (r *UserRepository) Delete(id int64) error {
 
 	return nil
 }
+
+func (r *UserRepository) List(ctx context.Context, params domain.UserListParams) (_ *domain.UserList, err error) {
+	sortBy, err := listSortField(params)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, finish := r.startOp(ctx, OpList)
+	defer func() { finish(err) }()
+
+	return listUsers(ctx, r.db, r.dialect, params, sortBy)
+}
+
+// listSortField returns the validated sort field of params.
+func listSortField(params domain.UserListParams) (domain.UserSortField, error) {
+	sortBy := params.SortBy
+	if sortBy == "" {
+		sortBy = domain.SortByID
+	}
+	if !domain.UserSortFields[sortBy] {
+		return "", fmt.Errorf("unsupported sort field %q", sortBy)
+	}
+	return sortBy, nil
+}
+
+// listUsers implements List for the SQL databases.
+func listUsers(ctx context.Context, q DBTX, d dialect, params domain.UserListParams, sortBy domain.UserSortField) (*domain.UserList, error) {
+	args := &queryArgs{dialect: d}
+	where := userFilterClauses(params.Filter, args)
+	list := &domain.UserList{Users: []domain.User{}}
+
+	if params.IncludeTotal {
+		var total int64
+		row := q.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+whereSQL(where), args.values...)
+		if err := row.Scan(&total); err != nil {
+			return nil, d.queryError(ctx, err)
+		}
+		list.Total = &total
+	}
+
+	if params.Cursor != nil {
+		if params.Cursor.SortBy != sortBy || params.Cursor.SortDesc != params.SortDesc {
+			return nil, domain.ErrInvalidCursor
+		}
+		clause, err := keysetClause(params.Cursor, args)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, clause)
+	}
+
+	direction := "ASC"
+	if params.SortDesc {
+		direction = "DESC"
+	}
+	orderBy := fmt.Sprintf(" ORDER BY %s %s", sortBy, direction)
+	if sortBy != domain.SortByID {
+		orderBy += fmt.Sprintf(", id %s", direction)
+	}
+
+	// Fetch one extra row to find out whether another page exists.
+	limit := " LIMIT " + args.add(params.Limit+1) + " OFFSET " + args.add(params.Offset)
+	query := "SELECT id, username, email, role, version, created_at, updated_at, deleted_at FROM users" +
+		whereSQL(where) + orderBy + limit
+
+	rows, err := q.QueryContext(ctx, query, args.values...)
+	if err != nil {
+		return nil, d.queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user domain.User
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Role, &user.Version, &user.CreatedAt, &user.UpdatedAt, &user.DeletedAt); err != nil {
+			return nil, d.queryError(ctx, err)
+		}
+		list.Users = append(list.Users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, d.queryError(ctx, err)
+	}
+
+	if len(list.Users) > params.Limit {
+		list.Users = list.Users[:params.Limit]
+		last := list.Users[len(list.Users)-1]
+		list.NextCursor = &domain.UserCursor{
+			SortBy:   sortBy,
+			SortDesc: params.SortDesc,
+			Value:    cursorValue(&last, sortBy),
+			ID:       last.ID,
+		}
+	}
+
+	return list, nil
+}
+
+func userFilterClauses(f domain.UserFilter, args *queryArgs) []string {
+	where := []string{"deleted_at IS NULL"}
+
+	if f.Deleted {
+		where[0] = "deleted_at IS NOT NULL"
+	}
+
+	if f.EmailPrefix != "" {
+		where = append(where, "email LIKE "+args.add(escapeLike(f.EmailPrefix)+"%")+" ESCAPE "+args.dialect.backslash)
+	}
+	if f.UsernamePrefix != "" {
+		where = append(where, "username LIKE "+args.add(escapeLike(f.UsernamePrefix)+"%")+" ESCAPE "+args.dialect.backslash)
+	}
+	if f.CreatedAfter != nil {
+		where = append(where, "created_at >= "+args.add(*f.CreatedAfter))
+	}
+	if f.CreatedBefore != nil {
+		where = append(where, "created_at < "+args.add(*f.CreatedBefore))
+	}
+
+	return where
+}
+
+func whereSQL(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(where, " AND ")
+}
+
+// keysetClause selects the rows that come after the cursor in the
+// (sort column, id) ordering.
+func keysetClause(c *domain.UserCursor, args *queryArgs) (string, error) {
+	op := ">"
+	if c.SortDesc {
+		op = "<"
+	}
+
+	if c.SortBy == domain.SortByID {
+		return "id " + op + " " + args.add(c.ID), nil
+	}
+
+	var value any = c.Value
+	if c.SortBy == domain.SortByCreatedAt || c.SortBy == domain.SortByUpdatedAt {
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return "", domain.ErrInvalidCursor
+		}
+		value = t
+	}
+
+	clause := fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[4]s AND id %[2]s %[5]s))",
+		c.SortBy, op, args.add(value), args.add(value), args.add(c.ID))
+	return clause, nil
+}
+
+func cursorValue(u *domain.User, sortBy domain.UserSortField) string {
+	switch sortBy {
+	case domain.SortByUsername:
+		return u.Username
+	case domain.SortByEmail:
+		return u.Email
+	case domain.SortByCreatedAt:
+		return u.CreatedAt.Format(time.RFC3339Nano)
+	case domain.SortByUpdatedAt:
+		return u.UpdatedAt.Format(time.RFC3339Nano)
+	default:
+		return ""
+	}
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,343 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"go-crud/internal/domain"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MemoryUserRepository is the in-memory implementation of
+// domain.UserRepository, see MemoryStore.
+type MemoryUserRepository struct {
+	store *MemoryStore
+	repoOptions
+}
+
+// NewMemoryUserRepository returns the user repository of a new, empty
+// MemoryStore.
+func NewMemoryUserRepository(opts ...Option) domain.UserRepository {
+	return NewMemoryStore(opts...).Repositories().Users
+}
+
+// copyUser returns a copy of u that shares no memory with it, without the
+// password hash unless withPassword is set.
+func copyUser(u domain.User, withPassword bool) *domain.User {
+	if !withPassword {
+		u.Password = ""
+	}
+	if u.DeletedAt != nil {
+		deletedAt := *u.DeletedAt
+		u.DeletedAt = &deletedAt
+	}
+	return &u
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *domain.User) (err error) {
+	ctx, finish := r.startOp(ctx, OpCreate)
+	defer func() { finish(err) }()
+
+	if user.Role == "" {
+		user.Role = domain.RoleUser
+	}
+
+	return r.store.write(ctx, func(d *memoryData) error {
+		now := memoryNow()
+		stored := domain.User{
+			ID:        d.lastUserID + 1,
+			Email:     user.Email,
+			Username:  user.Username,
+			Password:  user.Password,
+			Role:      user.Role,
+			Version:   1,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := d.checkUser(&stored); err != nil {
+			return err
+		}
+
+		d.lastUserID = stored.ID
+		d.users[stored.ID] = stored
+		user.ID, user.Version, user.CreatedAt, user.UpdatedAt = stored.ID, stored.Version, now, now
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id int64) (_ *domain.User, err error) {
+	ctx, finish := r.startOp(ctx, OpGetByID)
+	defer func() { finish(err) }()
+
+	var user *domain.User
+	err = r.store.read(ctx, func(d *memoryData) error {
+		stored, ok := d.users[id]
+		if !ok || stored.DeletedAt != nil {
+			return domain.ErrNotFound
+		}
+		user = copyUser(stored, false)
+		return nil
+	})
+	return user, err
+}
+
+func (r *MemoryUserRepository) GetByLogin(ctx context.Context, login string) (_ *domain.User, err error) {
+	ctx, finish := r.startOp(ctx, OpGetByLogin)
+	defer func() { finish(err) }()
+
+	var user *domain.User
+	err = r.store.read(ctx, func(d *memoryData) error {
+		for _, stored := range d.users {
+			if stored.DeletedAt == nil && (stored.Username == login || stored.Email == login) {
+				user = copyUser(stored, true)
+				return nil
+			}
+		}
+		return domain.ErrNotFound
+	})
+	return user, err
+}
+
+func (r *MemoryUserRepository) Update(ctx context.Context, id int64, upd *domain.UserUpdate) (err error) {
+	changed := upd.Username != nil || upd.Email != nil || upd.Password != nil || upd.Role != nil
+	if !changed && upd.Version == nil {
+		return nil
+	}
+
+	ctx, finish := r.startOp(ctx, OpUpdate)
+	defer func() { finish(err) }()
+
+	return r.store.write(ctx, func(d *memoryData) error {
+		user, ok := d.users[id]
+		if !ok || user.DeletedAt != nil {
+			return domain.ErrNotFound
+		}
+		if upd.Version != nil && user.Version != *upd.Version {
+			return domain.ErrConflict
+		}
+		if !changed {
+			return nil
+		}
+
+		if upd.Username != nil {
+			user.Username = *upd.Username
+		}
+		if upd.Email != nil {
+			user.Email = *upd.Email
+		}
+		if upd.Password != nil {
+			user.Password = *upd.Password
+		}
+		if upd.Role != nil {
+			user.Role = *upd.Role
+		}
+		if err := d.checkUser(&user); err != nil {
+			return err
+		}
+
+		user.UpdatedAt = memoryNow()
+		user.Version++
+		d.users[id] = user
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, id int64) (err error) {
+	ctx, finish := r.startOp(ctx, OpDelete)
+	defer func() { finish(err) }()
+
+	return r.store.write(ctx, func(d *memoryData) error {
+		user, ok := d.users[id]
+		if !ok || user.DeletedAt != nil {
+			return domain.ErrNotFound
+		}
+
+		now := memoryNow()
+		user.DeletedAt = &now
+		user.Version++
+		d.users[id] = user
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) Restore(ctx context.Context, id int64) (err error) {
+	ctx, finish := r.startOp(ctx, OpRestore)
+	defer func() { finish(err) }()
+
+	return r.store.write(ctx, func(d *memoryData) error {
+		user, ok := d.users[id]
+		if !ok || user.DeletedAt == nil {
+			return domain.ErrNotFound
+		}
+
+		// Someone may have taken the email or username in the meantime.
+		user.DeletedAt = nil
+		if err := d.checkUser(&user); err != nil {
+			return err
+		}
+		user.Version++
+		d.users[id] = user
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) HardDelete(ctx context.Context, id int64) (err error) {
+	ctx, finish := r.startOp(ctx, OpHardDelete)
+	defer func() { finish(err) }()
+
+	return r.store.write(ctx, func(d *memoryData) error {
+		if _, ok := d.users[id]; !ok {
+			return domain.ErrNotFound
+		}
+		d.deleteUser(id)
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (purged int64, err error) {
+	ctx, finish := r.startOp(ctx, OpPurge)
+	defer func() { finish(err) }()
+
+	err = r.store.write(ctx, func(d *memoryData) error {
+		for id, user := range d.users {
+			if user.DeletedAt != nil && user.DeletedAt.Before(deletedBefore) {
+				d.deleteUser(id)
+				purged++
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context, params domain.UserListParams) (_ *domain.UserList, err error) {
+	sortBy, err := listSortField(params)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, finish := r.startOp(ctx, OpList)
+	defer func() { finish(err) }()
+
+	var list *domain.UserList
+	err = r.store.read(ctx, func(d *memoryData) error {
+		list, err = d.listUsers(params, sortBy)
+		return err
+	})
+	return list, err
+}
+
+// listUsers mirrors the query listUsers builds for the SQL databases.
+func (d *memoryData) listUsers(params domain.UserListParams, sortBy domain.UserSortField) (*domain.UserList, error) {
+	users := []domain.User{}
+	for _, user := range d.users {
+		if matchesUserFilter(&user, params.Filter) {
+			users = append(users, user)
+		}
+	}
+
+	list := &domain.UserList{Users: []domain.User{}}
+	if params.IncludeTotal {
+		total := int64(len(users))
+		list.Total = &total
+	}
+
+	compare := func(a, b *domain.User) int {
+		c := compareUsers(a, b, sortBy)
+		if params.SortDesc {
+			return -c
+		}
+		return c
+	}
+
+	if params.Cursor != nil {
+		if params.Cursor.SortBy != sortBy || params.Cursor.SortDesc != params.SortDesc {
+			return nil, domain.ErrInvalidCursor
+		}
+		last, err := cursorUser(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		users = slices.DeleteFunc(users, func(u domain.User) bool {
+			return compare(&u, last) <= 0
+		})
+	}
+
+	slices.SortFunc(users, func(a, b domain.User) int {
+		return compare(&a, &b)
+	})
+
+	// Take one extra user to find out whether another page exists.
+	start := min(params.Offset, len(users))
+	end := min(start+params.Limit+1, len(users))
+	for _, user := range users[start:end] {
+		list.Users = append(list.Users, *copyUser(user, false))
+	}
+
+	if len(list.Users) > params.Limit {
+		list.Users = list.Users[:params.Limit]
+		last := list.Users[len(list.Users)-1]
+		list.NextCursor = &domain.UserCursor{
+			SortBy:   sortBy,
+			SortDesc: params.SortDesc,
+			Value:    cursorValue(&last, sortBy),
+			ID:       last.ID,
+		}
+	}
+
+	return list, nil
+}
+
+func matchesUserFilter(u *domain.User, f domain.UserFilter) bool {
+	switch {
+	case (u.DeletedAt != nil) != f.Deleted:
+		return false
+	case !strings.HasPrefix(u.Email, f.EmailPrefix):
+		return false
+	case !strings.HasPrefix(u.Username, f.UsernamePrefix):
+		return false
+	case f.CreatedAfter != nil && u.CreatedAt.Before(*f.CreatedAfter):
+		return false
+	case f.CreatedBefore != nil && !u.CreatedAt.Before(*f.CreatedBefore):
+		return false
+	}
+	return true
+}
+
+// compareUsers orders users by sortBy, then by id.
+func compareUsers(a, b *domain.User, sortBy domain.UserSortField) int {
+	var c int
+	switch sortBy {
+	case domain.SortByUsername:
+		c = strings.Compare(a.Username, b.Username)
+	case domain.SortByEmail:
+		c = strings.Compare(a.Email, b.Email)
+	case domain.SortByCreatedAt:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	case domain.SortByUpdatedAt:
+		c = a.UpdatedAt.Compare(b.UpdatedAt)
+	}
+	if c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ID, b.ID)
+}
+
+// cursorUser returns a user holding the sort key the cursor points at.
+func cursorUser(c *domain.UserCursor) (*domain.User, error) {
+	user := &domain.User{ID: c.ID}
+	switch c.SortBy {
+	case domain.SortByUsername:
+		user.Username = c.Value
+	case domain.SortByEmail:
+		user.Email = c.Value
+	case domain.SortByCreatedAt, domain.SortByUpdatedAt:
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, domain.ErrInvalidCursor
+		}
+		user.CreatedAt, user.UpdatedAt = t, t
+	}
+	return user, nil
+}
@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-crud/internal/config"
+	"go-crud/internal/domain"
+	"go-crud/internal/logging"
+	"go-crud/internal/migrate"
+	"go-crud/internal/repository/repotest"
+	"net/url"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// newPostgresDB returns a connection to a migrated scratch schema on the
+// server at dsn, a postgres:// URL, and drops the schema when the test
+// ends.
+func newPostgresDB(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+
+	admin, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := scratchName()
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("failed to create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Errorf("failed to drop schema: %v", err)
+		}
+	})
+
+	// Every connection of the pool works in the scratch schema.
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("TEST_POSTGRES_DSN must be a postgres:// URL: %v", err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	db, err := sql.Open("pgx", u.String())
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrate.ApplyMigrations(context.Background(), db, config.DriverPostgres, "", logging.Discard()); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	return db
+}
+
+func TestPostgresUserRepository_Contract(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	repotest.TestUserRepository(t, func(t *testing.T) domain.UserRepository {
+		return NewPostgresUserRepository(newPostgresDB(t, dsn), WithLogger(logging.Discard()))
+	})
+}
+
+func TestPostgresUserRepository_Create(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresUserRepository(db)
+	fixedTime := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	subtests := []struct {
+		name        string
+		expectedID  int64
+		expectedErr error
+		setupMock   func()
+	}{
+		{
+			name:       "Create success",
+			expectedID: 7,
+			setupMock: func() {
+				mock.ExpectQuery(`INSERT INTO users .* RETURNING id, version, created_at, updated_at`).
+					WithArgs("testuser", "test@email.com", "hash", domain.RoleUser).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "version", "created_at", "updated_at"}).
+						AddRow(7, 1, fixedTime, fixedTime))
+			},
+		},
+		{
+			name:        "Create duplicate username",
+			expectedErr: domain.ErrAlreadyExists,
+			setupMock: func() {
+				mock.ExpectQuery(`INSERT INTO users`).
+					WithArgs("testuser", "test@email.com", "hash", domain.RoleUser).
+					WillReturnError(&pgconn.PgError{Code: "23505", ConstraintName: "users_username_unique"})
+			},
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			subtest.setupMock()
+			user := &domain.User{Username: "testuser", Email: "test@email.com", Password: "hash"}
+
+			err := repo.Create(context.Background(), user)
+
+			if !errors.Is(err, subtest.expectedErr) {
+				t.Fatalf("expected error: %v, got: %v", subtest.expectedErr, err)
+			}
+			if user.ID != subtest.expectedID {
+				t.Errorf("expected ID: %d, got: %d", subtest.expectedID, user.ID)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestPostgresUserRepository_Update(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresUserRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET username = $1, role = $2, updated_at = NOW(), version = version + 1 WHERE id = $3 AND deleted_at IS NULL AND version = $4")).
+		WithArgs("newname", domain.RoleAdmin, 1, 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT version FROM users WHERE id = $1 AND deleted_at IS NULL")).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+
+	role := domain.RoleAdmin
+	err = repo.Update(context.Background(), 1, &domain.UserUpdate{Username: strPtr("newname"), Role: &role, Version: int64Ptr(3)})
+	if !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrConflict, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPostgresUserRepository_List(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresUserRepository(db)
+	fixedTime := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "username", "email", "role", "version", "created_at", "updated_at", "deleted_at"}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT COUNT(*) FROM users WHERE deleted_at IS NULL AND username LIKE $1 ESCAPE '\'`)).
+		WithArgs("al%").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM users WHERE deleted_at IS NULL AND username LIKE $1 ESCAPE '\' AND (username > $2 OR (username = $3 AND id > $4)) ORDER BY username ASC, id ASC LIMIT $5 OFFSET $6`)).
+		WithArgs("al%", "alex", "alex", 4, 3, 0).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(5, "alice", "alice@email.com", "user", 1, fixedTime, fixedTime, nil))
+
+	list, err := repo.List(context.Background(), domain.UserListParams{
+		Filter:       domain.UserFilter{UsernamePrefix: "al"},
+		SortBy:       domain.SortByUsername,
+		Limit:        2,
+		IncludeTotal: true,
+		Cursor:       &domain.UserCursor{SortBy: domain.SortByUsername, Value: "alex", ID: 4},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list.Users) != 1 || list.Users[0].ID != 5 {
+		t.Errorf("expected user 5, got: %+v", list.Users)
+	}
+	if list.Total == nil || *list.Total != 1 {
+		t.Errorf("expected total: 1, got: %v", list.Total)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
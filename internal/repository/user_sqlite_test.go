@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go-crud/internal/config"
+	"go-crud/internal/domain"
+	"go-crud/internal/logging"
+	"go-crud/internal/migrate"
+	"go-crud/internal/repository/repotest"
+	"go-crud/pkg/database"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSQLiteDB opens a migrated SQLite database in a temporary directory,
+// configured like the service's own connection.
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	cfg := config.DatabaseConfig{Driver: config.DriverSQLite, Path: filepath.Join(t.TempDir(), "test.db")}
+	db, err := database.NewConnection(cfg, logging.Discard())
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrate.ApplyMigrations(context.Background(), db, config.DriverSQLite, "", logging.Discard()); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	return db
+}
+
+func TestSQLiteUserRepository_Contract(t *testing.T) {
+	repotest.TestUserRepository(t, func(t *testing.T) domain.UserRepository {
+		return NewSQLiteUserRepository(newSQLiteDB(t), WithLogger(logging.Discard()))
+	})
+}
+
+func TestSQLiteUserRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLiteUserRepository(newSQLiteDB(t))
+
+	user := &domain.User{Username: "testuser", Email: "test@email.com", Password: "hash"}
+	if err := repo.Create(ctx, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user.ID == 0 || user.Version != 1 || user.Role != domain.RoleUser {
+		t.Fatalf("unexpected created user: %+v", user)
+	}
+	if user.CreatedAt.IsZero() || !user.CreatedAt.Equal(user.UpdatedAt) {
+		t.Fatalf("unexpected timestamps: %v, %v", user.CreatedAt, user.UpdatedAt)
+	}
+
+	got, err := repo.GetByLogin(ctx, "test@email.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ID != user.ID || got.Password != "hash" {
+		t.Errorf("expected user %d with password hash, got: %+v", user.ID, got)
+	}
+
+	err = repo.Create(ctx, &domain.User{Username: "other", Email: "test@email.com", Password: "hash"})
+	var constraintErr *domain.ConstraintError
+	if !errors.As(err, &constraintErr) || constraintErr.Field != "email" || !errors.Is(err, domain.ErrAlreadyExists) {
+		t.Errorf("expected duplicate email, got: %v", err)
+	}
+
+	err = repo.Create(ctx, &domain.User{Username: strings.Repeat("a", 101), Email: "long@email.com", Password: "hash"})
+	if !errors.As(err, &constraintErr) || constraintErr.Field != "username" || !errors.Is(err, domain.ErrValueTooLong) {
+		t.Errorf("expected username too long, got: %v", err)
+	}
+
+	name := "renamed"
+	stale := int64(5)
+	if err := repo.Update(ctx, user.ID, &domain.UserUpdate{Username: &name, Version: &stale}); !errors.Is(err, domain.ErrConflict) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrConflict, err)
+	}
+	if err := repo.Update(ctx, user.ID, &domain.UserUpdate{Username: &name, Version: &user.Version}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err = repo.GetByID(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Username != name || got.Version != 2 || got.UpdatedAt.Before(got.CreatedAt) {
+		t.Errorf("unexpected updated user: %+v", got)
+	}
+
+	if err := repo.Delete(ctx, user.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := repo.GetByID(ctx, user.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrNotFound, err)
+	}
+
+	// The email is free again while its owner is soft-deleted.
+	if err := repo.Create(ctx, &domain.User{Username: "testuser", Email: "test@email.com", Password: "hash"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	purged, err := repo.Purge(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("expected 1 purged user, got: %d", purged)
+	}
+	if err := repo.Restore(ctx, user.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrNotFound, err)
+	}
+}
+
+func TestSQLiteUserRepository_List(t *testing.T) {
+	ctx := context.Background()
+	repo := NewSQLiteUserRepository(newSQLiteDB(t))
+
+	for _, name := range []string{"alice", "bob", "a_c"} {
+		if err := repo.Create(ctx, &domain.User{Username: name, Email: name + "@email.com", Password: "hash"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	first, err := repo.List(ctx, domain.UserListParams{SortBy: domain.SortByCreatedAt, Limit: 2, IncludeTotal: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first.Users) != 2 || first.NextCursor == nil || first.Total == nil || *first.Total != 3 {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+
+	second, err := repo.List(ctx, domain.UserListParams{SortBy: domain.SortByCreatedAt, Limit: 2, Cursor: first.NextCursor})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(second.Users) != 1 || second.Users[0].Username != "a_c" {
+		t.Errorf("unexpected second page: %+v", second.Users)
+	}
+
+	filtered, err := repo.List(ctx, domain.UserListParams{Filter: domain.UserFilter{UsernamePrefix: "a_"}, Limit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(filtered.Users) != 1 || filtered.Users[0].Username != "a_c" {
+		t.Errorf("expected only a_c, got: %+v", filtered.Users)
+	}
+}
+
+func TestSQLiteRefreshTokenRepository(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	users := NewSQLiteUserRepository(db)
+	tokens := &RefreshTokenRepository{db: db, dialect: sqliteDialect, repoOptions: newRepoOptions(nil)}
+
+	user := &domain.User{Username: "testuser", Email: "test@email.com", Password: "hash"}
+	if err := users.Create(ctx, user); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	token := &domain.RefreshToken{UserID: user.ID, TokenHash: "hash", FamilyID: "family", ExpiresAt: expiresAt}
+	if err := tokens.Create(ctx, token); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := tokens.Create(ctx, &domain.RefreshToken{UserID: user.ID + 1, TokenHash: "other", FamilyID: "family", ExpiresAt: expiresAt})
+	if !errors.Is(err, domain.ErrInvalidReference) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrInvalidReference, err)
+	}
+
+	if err := tokens.Revoke(ctx, token.ID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tokens.Revoke(ctx, token.ID); !errors.Is(err, domain.ErrNotFound) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrNotFound, err)
+	}
+
+	got, err := tokens.GetByHash(ctx, "hash")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.ExpiresAt.Equal(expiresAt) || got.RevokedAt == nil {
+		t.Errorf("unexpected token: %+v", got)
+	}
+}
@@ -1,16 +1,84 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"go-crud/internal/config"
 	"go-crud/internal/domain"
+	"go-crud/internal/logging"
+	"go-crud/internal/migrate"
+	"go-crud/internal/repository/repotest"
+	"os"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
 )
 
+// scratchName returns a name for a database or schema of its own, so
+// contract tests against a shared server start empty.
+func scratchName() string {
+	return fmt.Sprintf("go_crud_test_%d", time.Now().UnixNano())
+}
+
+// newMySQLDB returns a connection to a migrated scratch database on the
+// server at dsn, a go-sql-driver DSN, and drops the database when the test
+// ends.
+func newMySQLDB(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("invalid TEST_MYSQL_DSN: %v", err)
+	}
+
+	admin, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	name := scratchName()
+	if _, err := admin.Exec("CREATE DATABASE " + name); err != nil {
+		t.Fatalf("failed to create database: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP DATABASE " + name); err != nil {
+			t.Errorf("failed to drop database: %v", err)
+		}
+	})
+
+	// The settings the service connects with, see database.NewConnection.
+	cfg.DBName = name
+	cfg.ParseTime = true
+	cfg.MultiStatements = true
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrate.ApplyMigrations(context.Background(), db, config.DriverMySQL, "", logging.Discard()); err != nil {
+		t.Fatalf("failed to apply migrations: %v", err)
+	}
+	return db
+}
+
+func TestUserRepository_Contract(t *testing.T) {
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN is not set")
+	}
+
+	repotest.TestUserRepository(t, func(t *testing.T) domain.UserRepository {
+		return NewUserRepository(newMySQLDB(t, dsn), WithLogger(logging.Discard()))
+	})
+}
+
 func TestUserRepository_Create(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -40,14 +108,16 @@ func TestUserRepository_Create(t *testing.T) {
 			expectedID:  1,
 			expectedErr: nil,
 			setupMock: func() {
+				mock.ExpectBegin()
 				mock.ExpectExec(`INSERT INTO users`).
-					WithArgs(user.Username, user.Email, user.Password).
+					WithArgs(user.Username, user.Email, user.Password, domain.RoleUser).
 					WillReturnResult(sqlmock.NewResult(1, 1))
 
-				mock.ExpectQuery(`SELECT created_at, updated_at FROM users WHERE id = ?`).
+				mock.ExpectQuery(`SELECT version, created_at, updated_at FROM users WHERE id = ?`).
 					WithArgs(1).
-					WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at"}).
-						AddRow(fixedTime, fixedTime))
+					WillReturnRows(sqlmock.NewRows([]string{"version", "created_at", "updated_at"}).
+						AddRow(1, fixedTime, fixedTime))
+				mock.ExpectCommit()
 			},
 		},
 		{
@@ -56,9 +126,26 @@ func TestUserRepository_Create(t *testing.T) {
 			expectedID:  0,
 			expectedErr: domain.ErrAlreadyExists,
 			setupMock: func() {
+				mock.ExpectBegin()
+				mock.ExpectExec(`INSERT INTO users`).
+					WithArgs(user.Username, user.Email, user.Password, domain.RoleUser).
+					WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'test@email.com-1' for key 'users.users_email_unique'"})
+				mock.ExpectRollback()
+			},
+		},
+		{
+			name:        "Create rolls back when the row cannot be read",
+			user:        &domain.User{Username: "other", Email: "other@email.com", Password: "hash"},
+			expectedErr: sql.ErrConnDone,
+			setupMock: func() {
+				mock.ExpectBegin()
 				mock.ExpectExec(`INSERT INTO users`).
-					WithArgs(user.Username, user.Email, user.Password).
-					WillReturnError(fmt.Errorf("Duplicate entry"))
+					WithArgs("other", "other@email.com", "hash", domain.RoleUser).
+					WillReturnResult(sqlmock.NewResult(2, 1))
+				mock.ExpectQuery(`SELECT version, created_at, updated_at FROM users WHERE id = ?`).
+					WithArgs(2).
+					WillReturnError(sql.ErrConnDone)
+				mock.ExpectRollback()
 			},
 		},
 	}
@@ -66,7 +153,7 @@ func TestUserRepository_Create(t *testing.T) {
 		t.Run(subtest.name, func(t *testing.T) {
 			subtest.setupMock()
 
-			err := repo.Create(subtest.user)
+			err := repo.Create(context.Background(), subtest.user)
 
 			if (subtest.expectedErr == nil && err != nil) || (subtest.expectedErr != nil && err == nil) {
 				t.Errorf("expected error: %v, got: %v", subtest.expectedErr, err)
@@ -83,11 +170,16 @@ func TestUserRepository_Create(t *testing.T) {
 					t.Error("Expected UpdatedAt to be set")
 				}
 			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
 		})
 	}
 }
 func strPtr(s string) *string { return &s }
 
+func int64Ptr(n int64) *int64 { return &n }
+
 func TestUserRepository_GetByID(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -112,15 +204,17 @@ func TestUserRepository_GetByID(t *testing.T) {
 				ID:        1,
 				Username:  "testuser",
 				Email:     "test@email.com",
+				Role:      domain.RoleUser,
+				Version:   3,
 				CreatedAt: fixedTime,
 				UpdatedAt: fixedTime,
 			},
 			expectedErr: nil,
 			setupMock: func() {
-				rows := sqlmock.NewRows([]string{"id", "username", "email", "created_at", "updated_at"}).
-					AddRow(1, "testuser", "test@email.com", fixedTime, fixedTime)
+				rows := sqlmock.NewRows([]string{"id", "username", "email", "role", "version", "created_at", "updated_at"}).
+					AddRow(1, "testuser", "test@email.com", "user", 3, fixedTime, fixedTime)
 
-				mock.ExpectQuery(`SELECT id, username, email, created_at, updated_at FROM users WHERE id = \?`).
+				mock.ExpectQuery(`SELECT id, username, email, role, version, created_at, updated_at FROM users WHERE id = \?`).
 					WithArgs(1).
 					WillReturnRows(rows)
 			},
@@ -131,7 +225,7 @@ func TestUserRepository_GetByID(t *testing.T) {
 			expectedUser: nil,
 			expectedErr:  domain.ErrNotFound,
 			setupMock: func() {
-				mock.ExpectQuery(`SELECT id, username, email, created_at, updated_at FROM users WHERE id = \?`).
+				mock.ExpectQuery(`SELECT id, username, email, role, version, created_at, updated_at FROM users WHERE id = \?`).
 					WithArgs(9999).
 					WillReturnError(sql.ErrNoRows)
 			},
@@ -142,7 +236,7 @@ func TestUserRepository_GetByID(t *testing.T) {
 		t.Run(subtest.name, func(t *testing.T) {
 			subtest.setupMock()
 
-			user, err := repo.GetByID(subtest.id)
+			user, err := repo.GetByID(context.Background(), subtest.id)
 
 			if (subtest.expectedErr == nil && err != nil) || (subtest.expectedErr != nil && err == nil) {
 				t.Errorf("expected error: %v, got: %v", subtest.expectedErr, err)
@@ -179,7 +273,7 @@ func TestUserRepository_Update(t *testing.T) {
 				Username: strPtr("newuser"),
 			},
 			setupMock: func() {
-				mock.ExpectExec(`UPDATE users SET username = \?, email = \?, updated_at = NOW\(\) WHERE id = \?`).
+				mock.ExpectExec(`UPDATE users SET username = \?, email = \?, updated_at = NOW\(\), version = version \+ 1 WHERE id = \? AND deleted_at IS NULL`).
 					WithArgs("newuser", "new@email.com", 1).
 					WillReturnResult(sqlmock.NewResult(0, 1))
 			},
@@ -192,12 +286,71 @@ func TestUserRepository_Update(t *testing.T) {
 				Email: strPtr("notfound@email.com"),
 			},
 			setupMock: func() {
-				mock.ExpectExec(`UPDATE users SET email = \?, updated_at = NOW\(\) WHERE id = \?`).
+				mock.ExpectExec(`UPDATE users SET email = \?, updated_at = NOW\(\), version = version \+ 1 WHERE id = \? AND deleted_at IS NULL`).
 					WithArgs("notfound@email.com", 999).
 					WillReturnResult(sqlmock.NewResult(0, 0))
 			},
 			expectedErr: domain.ErrNotFound,
 		},
+		{
+			name: "version matches",
+			id:   1,
+			update: &domain.UserUpdate{
+				Email:   strPtr("new@email.com"),
+				Version: int64Ptr(3),
+			},
+			setupMock: func() {
+				mock.ExpectExec(`UPDATE users SET email = \?, updated_at = NOW\(\), version = version \+ 1 WHERE id = \? AND deleted_at IS NULL AND version = \?`).
+					WithArgs("new@email.com", 1, 3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "version changed concurrently",
+			id:   1,
+			update: &domain.UserUpdate{
+				Email:   strPtr("new@email.com"),
+				Version: int64Ptr(3),
+			},
+			setupMock: func() {
+				mock.ExpectExec(`UPDATE users SET email = \?, updated_at = NOW\(\), version = version \+ 1 WHERE id = \? AND deleted_at IS NULL AND version = \?`).
+					WithArgs("new@email.com", 1, 3).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(`SELECT version FROM users WHERE id = \? AND deleted_at IS NULL`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+			},
+			expectedErr: domain.ErrConflict,
+		},
+		{
+			name: "versioned update of missing user",
+			id:   999,
+			update: &domain.UserUpdate{
+				Email:   strPtr("new@email.com"),
+				Version: int64Ptr(3),
+			},
+			setupMock: func() {
+				mock.ExpectExec(`UPDATE users SET email = \?, updated_at = NOW\(\), version = version \+ 1 WHERE id = \? AND deleted_at IS NULL AND version = \?`).
+					WithArgs("new@email.com", 999, 3).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectQuery(`SELECT version FROM users WHERE id = \? AND deleted_at IS NULL`).
+					WithArgs(999).
+					WillReturnError(sql.ErrNoRows)
+			},
+			expectedErr: domain.ErrNotFound,
+		},
+		{
+			name:   "no fields with stale version",
+			id:     1,
+			update: &domain.UserUpdate{Version: int64Ptr(3)},
+			setupMock: func() {
+				mock.ExpectQuery(`SELECT version FROM users WHERE id = \? AND deleted_at IS NULL`).
+					WithArgs(1).
+					WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+			},
+			expectedErr: domain.ErrConflict,
+		},
 		{
 			name:   "no fields to update",
 			id:     2,
@@ -213,7 +366,7 @@ func TestUserRepository_Update(t *testing.T) {
 				Email: strPtr("test@email.com"),
 			},
 			setupMock: func() {
-				mock.ExpectExec(`UPDATE users SET email = \?, updated_at = NOW\(\) WHERE id = \?`).
+				mock.ExpectExec(`UPDATE users SET email = \?, updated_at = NOW\(\), version = version \+ 1 WHERE id = \? AND deleted_at IS NULL`).
 					WithArgs("test@email.com", 1).
 					WillReturnError(fmt.Errorf("connection lost"))
 			},
@@ -225,11 +378,17 @@ func TestUserRepository_Update(t *testing.T) {
 		t.Run(subtest.name, func(t *testing.T) {
 			subtest.setupMock()
 
-			err := repo.Update(subtest.id, subtest.update)
+			err := repo.Update(context.Background(), subtest.id, subtest.update)
 
 			if (subtest.expectedErr == nil && err != nil) || (subtest.expectedErr != nil && err == nil) {
 				t.Errorf("expected error: %v, got: %v", subtest.expectedErr, err)
 			}
+			if (subtest.expectedErr == domain.ErrNotFound || subtest.expectedErr == domain.ErrConflict) && !errors.Is(err, subtest.expectedErr) {
+				t.Errorf("expected error: %v, got: %v", subtest.expectedErr, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
 		})
 	}
 }
@@ -253,7 +412,7 @@ func TestUserRepository_Delete(t *testing.T) {
 			id:          1,
 			expectedErr: nil,
 			setupMock: func() {
-				mock.ExpectExec(`DELETE FROM users WHERE id = \?`).
+				mock.ExpectExec(`UPDATE users SET deleted_at = NOW\(\), version = version \+ 1 WHERE id = \? AND deleted_at IS NULL`).
 					WithArgs(1).
 					WillReturnResult(sqlmock.NewResult(0, 1))
 			},
@@ -263,7 +422,7 @@ func TestUserRepository_Delete(t *testing.T) {
 			id:          9999,
 			expectedErr: domain.ErrNotFound,
 			setupMock: func() {
-				mock.ExpectExec(`DELETE FROM users WHERE id = \?`).
+				mock.ExpectExec(`UPDATE users SET deleted_at = NOW\(\), version = version \+ 1 WHERE id = \? AND deleted_at IS NULL`).
 					WithArgs(9999).
 					WillReturnResult(sqlmock.NewResult(0, 0))
 			},
@@ -273,7 +432,7 @@ func TestUserRepository_Delete(t *testing.T) {
 		t.Run(subtest.name, func(t *testing.T) {
 			subtest.setupMock()
 
-			err := repo.Delete(subtest.id)
+			err := repo.Delete(context.Background(), subtest.id)
 
 			if (subtest.expectedErr == nil && err != nil) || (subtest.expectedErr != err && err == nil) {
 				t.Errorf("expected errors: %v, got: %v", subtest.expectedErr, err)
@@ -281,3 +440,271 @@ func TestUserRepository_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestUserRepository_RestoreAndHardDelete(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+	subtests := []struct {
+		name        string
+		call        func(ctx context.Context, id int64) error
+		id          int64
+		expectedErr error
+		setupMock   func()
+	}{
+		{
+			name: "restore deleted user",
+			call: repo.Restore,
+			id:   1,
+			setupMock: func() {
+				mock.ExpectExec(`UPDATE users SET deleted_at = NULL, version = version \+ 1 WHERE id = \? AND deleted_at IS NOT NULL`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+		{
+			name:        "restore user that is not deleted",
+			call:        repo.Restore,
+			id:          2,
+			expectedErr: domain.ErrNotFound,
+			setupMock: func() {
+				mock.ExpectExec(`UPDATE users SET deleted_at = NULL, version = version \+ 1 WHERE id = \? AND deleted_at IS NOT NULL`).
+					WithArgs(2).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			name: "hard delete",
+			call: repo.HardDelete,
+			id:   1,
+			setupMock: func() {
+				mock.ExpectExec(`DELETE FROM users WHERE id = \?`).
+					WithArgs(1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+		},
+	}
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			subtest.setupMock()
+
+			err := subtest.call(context.Background(), subtest.id)
+
+			if !errors.Is(err, subtest.expectedErr) {
+				t.Errorf("expected error: %v, got: %v", subtest.expectedErr, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestUserRepository_Purge(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+	cutoff := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	query := `DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < \? LIMIT \?`
+	mock.ExpectExec(query).WithArgs(cutoff, purgeBatchSize).WillReturnResult(sqlmock.NewResult(0, purgeBatchSize))
+	mock.ExpectExec(query).WithArgs(cutoff, purgeBatchSize).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	purged, err := repo.Purge(context.Background(), cutoff)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if purged != purgeBatchSize+3 {
+		t.Errorf("expected purged: %d, got: %d", purgeBatchSize+3, purged)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestUserRepository_List(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+	fixedTime := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	columns := []string{"id", "username", "email", "role", "version", "created_at", "updated_at", "deleted_at"}
+
+	subtests := []struct {
+		name           string
+		params         domain.UserListParams
+		expectedIDs    []int64
+		expectedCursor *domain.UserCursor
+		expectedTotal  *int64
+		expectedErr    error
+		setupMock      func()
+	}{
+		{
+			name:        "first page with more rows",
+			params:      domain.UserListParams{SortBy: domain.SortByID, Limit: 2},
+			expectedIDs: []int64{1, 2},
+			expectedCursor: &domain.UserCursor{
+				SortBy: domain.SortByID,
+				ID:     2,
+			},
+			setupMock: func() {
+				mock.ExpectQuery(`SELECT id, username, email, role, version, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL ORDER BY id ASC LIMIT \? OFFSET \?`).
+					WithArgs(3, 0).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "a", "a@email.com", "user", 1, fixedTime, fixedTime, nil).
+						AddRow(2, "b", "b@email.com", "user", 1, fixedTime, fixedTime, nil).
+						AddRow(3, "c", "c@email.com", "user", 1, fixedTime, fixedTime, nil))
+			},
+		},
+		{
+			name: "filtered with total",
+			params: domain.UserListParams{
+				Filter:       domain.UserFilter{EmailPrefix: "a_b"},
+				SortBy:       domain.SortByID,
+				Limit:        10,
+				IncludeTotal: true,
+			},
+			expectedIDs:   []int64{1},
+			expectedTotal: func() *int64 { n := int64(1); return &n }(),
+			setupMock: func() {
+				mock.ExpectQuery(`SELECT COUNT\(\*\) FROM users WHERE deleted_at IS NULL AND email LIKE \?`).
+					WithArgs(`a\_b%`).
+					WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+				mock.ExpectQuery(`SELECT id, username, email, role, version, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL AND email LIKE \? ESCAPE '\\\\' ORDER BY id ASC LIMIT \? OFFSET \?`).
+					WithArgs(`a\_b%`, 11, 0).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(1, "a", "a_b@email.com", "user", 1, fixedTime, fixedTime, nil))
+			},
+		},
+		{
+			name: "keyset on sort column",
+			params: domain.UserListParams{
+				SortBy:   domain.SortByUsername,
+				SortDesc: true,
+				Limit:    10,
+				Cursor:   &domain.UserCursor{SortBy: domain.SortByUsername, SortDesc: true, Value: "m", ID: 5},
+			},
+			expectedIDs: []int64{4},
+			setupMock: func() {
+				mock.ExpectQuery(`SELECT id, username, email, role, version, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NULL AND \(username < \? OR \(username = \? AND id < \?\)\) ORDER BY username DESC, id DESC LIMIT \? OFFSET \?`).
+					WithArgs("m", "m", 5, 11, 0).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(4, "l", "l@email.com", "user", 1, fixedTime, fixedTime, nil))
+			},
+		},
+		{
+			name: "deleted users",
+			params: domain.UserListParams{
+				Filter: domain.UserFilter{Deleted: true},
+				SortBy: domain.SortByID,
+				Limit:  10,
+			},
+			expectedIDs: []int64{6},
+			setupMock: func() {
+				mock.ExpectQuery(`SELECT id, username, email, role, version, created_at, updated_at, deleted_at FROM users WHERE deleted_at IS NOT NULL ORDER BY id ASC LIMIT \? OFFSET \?`).
+					WithArgs(11, 0).
+					WillReturnRows(sqlmock.NewRows(columns).
+						AddRow(6, "f", "f@email.com", "user", 2, fixedTime, fixedTime, fixedTime))
+			},
+		},
+		{
+			name: "cursor for different sort",
+			params: domain.UserListParams{
+				SortBy: domain.SortByEmail,
+				Limit:  10,
+				Cursor: &domain.UserCursor{SortBy: domain.SortByID, ID: 5},
+			},
+			expectedErr: domain.ErrInvalidCursor,
+			setupMock:   func() {},
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			subtest.setupMock()
+
+			list, err := repo.List(context.Background(), subtest.params)
+
+			if err != subtest.expectedErr {
+				t.Fatalf("expected error: %v, got: %v", subtest.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+
+			gotIDs := []int64{}
+			for _, u := range list.Users {
+				gotIDs = append(gotIDs, u.ID)
+			}
+			if !reflect.DeepEqual(subtest.expectedIDs, gotIDs) {
+				t.Errorf("expected ids: %v, got: %v", subtest.expectedIDs, gotIDs)
+			}
+			if !reflect.DeepEqual(subtest.expectedCursor, list.NextCursor) {
+				t.Errorf("expected cursor: %+v, got: %+v", subtest.expectedCursor, list.NextCursor)
+			}
+			if !reflect.DeepEqual(subtest.expectedTotal, list.Total) {
+				t.Errorf("expected total: %v, got: %v", subtest.expectedTotal, list.Total)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestUserRepository_QueryTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db, WithQueryTimeouts(config.QueryTimeouts{
+		Default:      time.Second,
+		PerOperation: map[string]time.Duration{OpGetByID: 10 * time.Millisecond},
+	}))
+
+	mock.ExpectQuery(`SELECT id, username, email, role, version, created_at, updated_at FROM users WHERE id = \?`).
+		WithArgs(1).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	_, err = repo.GetByID(context.Background(), 1)
+	if !errors.Is(err, domain.ErrTimeout) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrTimeout, err)
+	}
+}
+
+func TestUserRepository_Canceled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock database: %v", err)
+	}
+	defer db.Close()
+
+	repo := NewUserRepository(db)
+
+	mock.ExpectQuery(`SELECT id, username, email, role, version, created_at, updated_at FROM users WHERE id = \?`).
+		WithArgs(1).
+		WillDelayFor(time.Second).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	_, err = repo.GetByID(ctx, 1)
+	if !errors.Is(err, domain.ErrCanceled) {
+		t.Errorf("expected error: %v, got: %v", domain.ErrCanceled, err)
+	}
+}
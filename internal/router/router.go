@@ -2,12 +2,81 @@
 package router
 
 import (
-	"go-crud/internal/handler"
 	"net/http"
+	"sync"
 )
 
-func NewRouter(h *handler.Handler) *http.ServeMux {
-	mux := http.NewServeMux()
-	h.RegisterRoutes(mux)
-	return mux
+// Middleware wraps a handler with cross-cutting behavior.
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes middlewares so that the first one is the outermost.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			h = middlewares[i](h)
+		}
+		return h
+	}
+}
+
+// Registrar is implemented by anything that contributes routes.
+type Registrar interface {
+	RegisterRoutes(routes *Group)
+}
+
+// Group registers routes on a shared mux, wrapping each of them with the
+// middlewares of the group and of all its parent groups.
+type Group struct {
+	mux         *http.ServeMux
+	middlewares []Middleware
+}
+
+// Group returns a child group whose routes additionally pass through
+// middlewares.
+func (g *Group) Group(middlewares ...Middleware) *Group {
+	return &Group{
+		mux:         g.mux,
+		middlewares: append(append([]Middleware{}, g.middlewares...), middlewares...),
+	}
+}
+
+// Handle registers h for pattern, wrapped by the group middlewares and
+// then by the per-route middlewares.
+func (g *Group) Handle(pattern string, h http.Handler, middlewares ...Middleware) {
+	all := append(append([]Middleware{}, g.middlewares...), middlewares...)
+	g.mux.Handle(pattern, Chain(all...)(h))
+}
+
+func (g *Group) HandleFunc(pattern string, h http.HandlerFunc, middlewares ...Middleware) {
+	g.Handle(pattern, h, middlewares...)
+}
+
+// Router is the root route group. Global middlewares registered with Use
+// run for every request, including those that match no route.
+type Router struct {
+	*Group
+	global  []Middleware
+	once    sync.Once
+	handler http.Handler
+}
+
+func NewRouter(registrars ...Registrar) *Router {
+	r := &Router{Group: &Group{mux: http.NewServeMux()}}
+	for _, registrar := range registrars {
+		registrar.RegisterRoutes(r.Group)
+	}
+	return r
+}
+
+// Use appends global middlewares. It must be called before the router
+// starts serving requests.
+func (r *Router) Use(middlewares ...Middleware) {
+	r.global = append(r.global, middlewares...)
+}
+
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.once.Do(func() {
+		r.handler = Chain(r.global...)(r.mux)
+	})
+	r.handler.ServeHTTP(w, req)
 }
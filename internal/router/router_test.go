@@ -0,0 +1,76 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func tag(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name+">")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type registrarFunc func(*Group)
+
+func (f registrarFunc) RegisterRoutes(routes *Group) { f(routes) }
+
+func TestRouter_MiddlewareOrder(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "handler")
+	})
+
+	r := NewRouter(registrarFunc(func(routes *Group) {
+		routes.Handle("/plain", ok)
+		routes.Handle("/route", ok, tag("route"))
+
+		group := routes.Group(tag("group"))
+		group.Handle("/group", ok)
+		group.Group(tag("nested")).Handle("/nested", ok, tag("route"))
+	}))
+	r.Use(tag("global1"), tag("global2"))
+
+	tests := []struct {
+		path     string
+		wantBody string
+	}{
+		{path: "/plain", wantBody: "global1>global2>handler"},
+		{path: "/route", wantBody: "global1>global2>route>handler"},
+		{path: "/group", wantBody: "global1>global2>group>handler"},
+		{path: "/nested", wantBody: "global1>global2>group>nested>route>handler"},
+		{path: "/missing", wantBody: "global1>global2>404 page not found"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+
+			if got := strings.TrimSpace(w.Body.String()); got != test.wantBody {
+				t.Errorf("expected body: %s, got: %s", test.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestGroup_DoesNotShareMiddlewareSlices(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	root := &Group{mux: http.NewServeMux(), middlewares: make([]Middleware, 0, 4)}
+
+	a := root.Group(tag("a"))
+	b := root.Group(tag("b"))
+	a.Handle("/a", ok)
+	b.Handle("/b", ok)
+
+	w := httptest.NewRecorder()
+	root.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+	if got := w.Body.String(); got != "a>" {
+		t.Errorf("expected body: a>, got: %s", got)
+	}
+}
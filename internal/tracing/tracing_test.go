@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"context"
+	"go-crud/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	ctx := context.Background()
+	previous := otel.GetTracerProvider()
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	t.Run("unsupported exporter", func(t *testing.T) {
+		if _, err := Setup(ctx, config.TracingConfig{Exporter: "zipkin"}); err == nil {
+			t.Errorf("expected an error for an unsupported exporter")
+		}
+	})
+
+	t.Run("file exporter", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "traces.json")
+		shutdown, err := Setup(ctx, config.TracingConfig{Exporter: ExporterFile, File: path, SampleRatio: 1, ServiceName: "go-crud-test"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		_, span := Tracer().Start(ctx, "test span")
+		span.End()
+		if err := shutdown(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, want := range []string{`"Name":"test span"`, `"go-crud-test"`} {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("expected the trace file to contain %s, got: %s", want, data)
+			}
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package validation
+
+import (
+	"fmt"
+	"go-crud/internal/domain"
+	"regexp"
+)
+
+// Limits mirror the column sizes of the users table. Passwords are capped
+// at bcrypt's 72 byte input limit.
+const (
+	EmailMaxLen      = 100
+	UsernameMinLen   = 3
+	UsernameMaxLen   = 100
+	PasswordMinLen   = 8
+	PasswordMaxBytes = 72
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// User validates a user about to be created. Password must still be the
+// plaintext value.
+func User(u *domain.User) error {
+	v := &Validator{}
+	checkEmail(v, u.Email)
+	checkUsername(v, u.Username)
+	checkPassword(v, u.Password)
+	return v.Err()
+}
+
+// UserUpdate validates only the fields present in the update.
+func UserUpdate(upd *domain.UserUpdate) error {
+	v := &Validator{}
+	if upd.Email != nil {
+		checkEmail(v, *upd.Email)
+	}
+	if upd.Username != nil {
+		checkUsername(v, *upd.Username)
+	}
+	if upd.Password != nil {
+		checkPassword(v, *upd.Password)
+	}
+	if upd.Role != nil {
+		v.Check(upd.Role.Valid(), "role", "must be one of 'user' or 'admin'")
+	}
+	return v.Err()
+}
+
+func checkEmail(v *Validator, email string) {
+	v.Check(NotBlank(email), "email", "is required")
+	v.Check(MaxLen(email, EmailMaxLen), "email", fmt.Sprintf("must be at most %d characters", EmailMaxLen))
+	v.Check(Email(email), "email", "must be a valid email address")
+}
+
+func checkUsername(v *Validator, username string) {
+	v.Check(NotBlank(username), "username", "is required")
+	v.Check(MinLen(username, UsernameMinLen), "username", fmt.Sprintf("must be at least %d characters", UsernameMinLen))
+	v.Check(MaxLen(username, UsernameMaxLen), "username", fmt.Sprintf("must be at most %d characters", UsernameMaxLen))
+	v.Check(Matches(username, usernamePattern), "username", "may only contain letters, digits, '.', '_' and '-'")
+}
+
+func checkPassword(v *Validator, password string) {
+	v.Check(password != "", "password", "is required")
+	v.Check(MinLen(password, PasswordMinLen), "password", fmt.Sprintf("must be at least %d characters", PasswordMinLen))
+	v.Check(MaxBytes(password, PasswordMaxBytes), "password", fmt.Sprintf("must be at most %d bytes", PasswordMaxBytes))
+	v.Check(HasLetterAndDigit(password), "password", "must contain at least one letter and one digit")
+}
@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"errors"
+	"go-crud/internal/domain"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestUser(t *testing.T) {
+	subtests := []struct {
+		name     string
+		user     domain.User
+		expected Errors
+	}{
+		{
+			name:     "valid",
+			user:     domain.User{Email: "test@email.com", Username: "test_user-1", Password: "password123"},
+			expected: nil,
+		},
+		{
+			name: "all fields empty",
+			user: domain.User{},
+			expected: Errors{
+				{Field: "email", Message: "is required"},
+				{Field: "username", Message: "is required"},
+				{Field: "password", Message: "is required"},
+			},
+		},
+		{
+			name: "invalid formats",
+			user: domain.User{Email: "Test <test@email.com>", Username: "ab", Password: "passwordonly"},
+			expected: Errors{
+				{Field: "email", Message: "must be a valid email address"},
+				{Field: "username", Message: "must be at least 3 characters"},
+				{Field: "password", Message: "must contain at least one letter and one digit"},
+			},
+		},
+		{
+			name: "too long",
+			user: domain.User{
+				Email:    strings.Repeat("a", 95) + "@e.com",
+				Username: strings.Repeat("a", 101),
+				Password: strings.Repeat("a1", 37),
+			},
+			expected: Errors{
+				{Field: "email", Message: "must be at most 100 characters"},
+				{Field: "username", Message: "must be at most 100 characters"},
+				{Field: "password", Message: "must be at most 72 bytes"},
+			},
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			err := User(&subtest.user)
+
+			if subtest.expected == nil {
+				if err != nil {
+					t.Errorf("expected no error, got: %v", err)
+				}
+				return
+			}
+
+			var got Errors
+			if !errors.As(err, &got) {
+				t.Fatalf("expected validation errors, got: %v", err)
+			}
+			if !reflect.DeepEqual(subtest.expected, got) {
+				t.Errorf("expected errors: %v, got: %v", subtest.expected, got)
+			}
+		})
+	}
+}
+
+func TestUserUpdate(t *testing.T) {
+	subtests := []struct {
+		name     string
+		update   domain.UserUpdate
+		expected Errors
+	}{
+		{
+			name:     "empty update",
+			update:   domain.UserUpdate{},
+			expected: nil,
+		},
+		{
+			name:     "valid email only",
+			update:   domain.UserUpdate{Email: strPtr("new@email.com")},
+			expected: nil,
+		},
+		{
+			name:   "blank username",
+			update: domain.UserUpdate{Username: strPtr(" ")},
+			expected: Errors{
+				{Field: "username", Message: "is required"},
+			},
+		},
+	}
+
+	for _, subtest := range subtests {
+		t.Run(subtest.name, func(t *testing.T) {
+			err := UserUpdate(&subtest.update)
+
+			var got Errors
+			errors.As(err, &got)
+			if !reflect.DeepEqual(subtest.expected, got) {
+				t.Errorf("expected errors: %v, got: %v", subtest.expected, got)
+			}
+		})
+	}
+}
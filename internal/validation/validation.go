@@ -0,0 +1,102 @@
+// Package validation checks request payloads and reports per-field errors
+package validation
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors is returned when one or more fields fail validation.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Validator collects the field errors of a single payload. Only the first
+// failing rule is recorded for each field.
+type Validator struct {
+	errs Errors
+}
+
+func (v *Validator) Check(ok bool, field, message string) {
+	if ok || v.hasError(field) {
+		return
+	}
+	v.errs = append(v.errs, FieldError{Field: field, Message: message})
+}
+
+func (v *Validator) Err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+func (v *Validator) hasError(field string) bool {
+	for _, fe := range v.errs {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func NotBlank(s string) bool {
+	return strings.TrimSpace(s) != ""
+}
+
+// MinLen and MaxLen count characters, matching varchar semantics.
+func MinLen(s string, n int) bool {
+	return utf8.RuneCountInString(s) >= n
+}
+
+func MaxLen(s string, n int) bool {
+	return utf8.RuneCountInString(s) <= n
+}
+
+func MaxBytes(s string, n int) bool {
+	return len(s) <= n
+}
+
+func Matches(s string, re *regexp.Regexp) bool {
+	return re.MatchString(s)
+}
+
+// Email accepts a bare address such as user@example.com, rejecting
+// display names and addresses without a dotted domain.
+func Email(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s || addr.Name != "" {
+		return false
+	}
+	at := strings.LastIndex(s, "@")
+	return strings.Contains(s[at+1:], ".")
+}
+
+// HasLetterAndDigit reports whether s contains at least one letter and
+// at least one digit.
+func HasLetterAndDigit(s string) bool {
+	var letter, digit bool
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return letter && digit
+}
@@ -1,39 +1,286 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"go-crud/internal/auth"
+	"go-crud/internal/authz"
 	"go-crud/internal/config"
+	"go-crud/internal/domain"
 	"go-crud/internal/handler"
+	"go-crud/internal/health"
+	"go-crud/internal/logging"
+	"go-crud/internal/metrics"
 	"go-crud/internal/migrate"
+	"go-crud/internal/password"
+	"go-crud/internal/purge"
 	"go-crud/internal/repository"
 	"go-crud/internal/router"
+	"go-crud/internal/tracing"
 	"go-crud/pkg/database"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Printf(".env file not found")
+	envErr := godotenv.Load()
+
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		config.PrintUsage(os.Stdout)
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\nRun with -h to list the flags.\n", err)
+		os.Exit(2)
+	}
+
+	command := ""
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	validate := cfg.Validate
+	switch command {
+	case "", "config":
+	case "migrate":
+		// Migrations need nothing but the database.
+		validate = cfg.ValidateDatabase
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected migrate or config\n", command)
+		os.Exit(2)
+	}
+
+	if command == "config" {
+		// Printed even when invalid, which is when it helps the most.
+		if err := cfg.Print(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to print config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if command == "config" {
+		return
+	}
+
+	logger, err := logging.New(cfg.Logging, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid logging config: %v\n", err)
+		os.Exit(1)
 	}
-	dbConfig := config.LoadDatabaseConfig()
-	db, err := database.NewConnection(dbConfig)
+	slog.SetDefault(logger)
+
+	if envErr != nil {
+		logger.Debug(".env file not found")
+	}
+
+	if command == "migrate" {
+		err = runMigrate(cfg, args, logger)
+	} else {
+		err = run(cfg, logger)
+	}
+	if err != nil {
+		logger.Error("exiting", "error", err)
+		os.Exit(1)
+	}
+}
+
+func runMigrate(cfg *config.Config, args []string, logger *slog.Logger) error {
+	cli := &migrate.CLI{
+		Connect: func() (*sql.DB, error) {
+			return database.NewConnection(cfg.Database, logger)
+		},
+		Driver: cfg.Database.Driver,
+		Dir:    cfg.Migration.Dir,
+		Out:    os.Stdout,
+	}
+	return cli.Run(args)
+}
+
+func run(cfg *config.Config, logger *slog.Logger) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		return fmt.Errorf("invalid tracing config: %w", err)
 	}
+	// Runs last, so spans of the shutdown itself are flushed too.
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Error("failed to flush traces", "error", err)
+		}
+	}()
+
+	serverConfig := cfg.Server
+	checks := health.NewRegistry(serverConfig.HealthCheckTimeout)
+	appMetrics := metrics.New()
 
-	if err := migrate.ApplyMigrations(db); err != nil {
-		log.Fatalf("Migrations failed: %v", err)
+	dbConfig := cfg.Database
+	repoOpts := []repository.Option{
+		repository.WithQueryTimeouts(dbConfig.QueryTimeouts),
+		repository.WithLogger(logger),
+		repository.WithQueryObserver(appMetrics),
 	}
 
-	defer db.Close()
+	var (
+		repos     domain.Repositories
+		txManager domain.TxManager
+	)
+	if dbConfig.Driver == config.DriverMemory {
+		logger.Warn("using the in-memory store, all data is lost on shutdown")
+		store := repository.NewMemoryStore(repoOpts...)
+		repos, txManager = store.Repositories(), store
+	} else {
+		store, err := repository.NewStore(dbConfig.Driver, repoOpts...)
+		if err != nil {
+			return err
+		}
+
+		db, err := database.NewConnection(dbConfig, logger)
+		if err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		defer func() {
+			if err := db.Close(); err != nil {
+				logger.Error("failed to close database", "error", err)
+				return
+			}
+			logger.Info("database connection closed")
+		}()
+
+		if cfg.Migration.AutoMigrate {
+			if err := migrate.ApplyMigrations(ctx, db, dbConfig.Driver, cfg.Migration.Dir, logger); err != nil {
+				return fmt.Errorf("migrations failed: %w", err)
+			}
+		}
+
+		appMetrics.RegisterDB(db, dbConfig.Driver)
+		checks.Register("database", health.Ping(db))
+		checks.Register("migrations", health.CheckerFunc(func(ctx context.Context) error {
+			return migrate.CheckSchema(ctx, db)
+		}))
+
+		repos = store.Repositories(db)
+		txManager = repository.NewTxManager(db, store, repository.WithTxLogger(logger))
+	}
+
+	passwords, err := password.NewServiceFromConfig(cfg.Password)
+	if err != nil {
+		return fmt.Errorf("invalid password config: %w", err)
+	}
+
+	authConfig := cfg.Auth
+	tokens, err := auth.NewTokenManagerFromConfig(authConfig)
+	if err != nil {
+		return fmt.Errorf("invalid auth config: %w", err)
+	}
+
+	if err := auth.EnsureAdmin(ctx, repos.Users, passwords, authConfig.InitialAdmin, logger); err != nil {
+		return err
+	}
+
+	authService, err := auth.NewService(
+		repos,
+		txManager,
+		passwords,
+		tokens,
+		authConfig.RefreshTokenTTL,
+		logger,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create auth service: %w", err)
+	}
 
 	deps := handler.Dependencies{
-		UserRepo: repository.NewUserRepository(db),
+		UserRepo:  repos.Users,
+		Tx:        txManager,
+		Passwords: passwords,
+		Auth:      authService,
+		Tokens:    tokens,
+		Policy:    authz.NewPolicy(authz.DefaultRules),
+		Health:    checks,
+		Metrics:   appMetrics,
+		Logger:    logger,
+	}
+
+	h := handler.NewHandler(deps)
+	router := router.NewRouter(h)
+	router.Use(
+		handler.RequestID,
+		handler.Trace,
+		handler.AccessLog(logger),
+		handler.Recover(logger),
+		handler.MaxBodySize(serverConfig.MaxBodyBytes),
+	)
+	server := &http.Server{
+		Addr:              serverConfig.Addr,
+		Handler:           router,
+		ReadTimeout:       serverConfig.ReadTimeout,
+		ReadHeaderTimeout: serverConfig.ReadHeaderTimeout,
+		WriteTimeout:      serverConfig.WriteTimeout,
+		IdleTimeout:       serverConfig.IdleTimeout,
+		MaxHeaderBytes:    serverConfig.MaxHeaderBytes,
+	}
+
+	purgeCtx, cancelPurge := context.WithCancel(ctx)
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		purge.NewJob(repos.Users, cfg.Purge, logger).Run(purgeCtx)
+	}()
+	// Runs before the database is closed.
+	defer func() {
+		cancelPurge()
+		<-purgeDone
+	}()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("listening", "addr", serverConfig.Addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("server failed: %w", err)
+	case <-ctx.Done():
+		stop()
+	}
+
+	// Fail readiness first so load balancers stop routing here while the
+	// server still serves.
+	checks.Drain()
+	if serverConfig.DrainDelay > 0 {
+		logger.Info("draining, waiting for load balancers", "delay", serverConfig.DrainDelay)
+		time.Sleep(serverConfig.DrainDelay)
+	}
+
+	logger.Info("shutting down, draining in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), serverConfig.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("server failed: %w", err)
 	}
-	handler := handler.NewHandler(deps)
-	router := router.NewRouter(handler)
 
-	http.ListenAndServe(":8080", router)
+	logger.Info("server stopped")
+	return nil
 }
@@ -4,27 +4,61 @@ import (
 	"database/sql"
 	"fmt"
 	"go-crud/internal/config"
-	"log"
+	"log/slog"
+	"net"
+	"net/url"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
-func NewConnection(cfg config.DatabaseConfig) (*sql.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4&multiStatements=true",
-		cfg.User,
-		cfg.Password,
-		cfg.Host,
-		cfg.Port,
-		cfg.DBName,
-	)
+// NewConnection opens a connection pool for the configured driver and
+// checks that the database is reachable.
+func NewConnection(cfg config.DatabaseConfig, logger *slog.Logger) (*sql.DB, error) {
+	var driverName, dsn, product string
+	database := cfg.DBName
+	switch cfg.Driver {
+	case config.DriverMySQL:
+		driverName, product = "mysql", "MySQL"
+		dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4&multiStatements=true",
+			cfg.User,
+			cfg.Password,
+			cfg.Host,
+			cfg.Port,
+			cfg.DBName,
+		)
+	case config.DriverPostgres:
+		driverName, product = "pgx", "PostgreSQL"
+		dsn = (&url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(cfg.User, cfg.Password),
+			Host:     net.JoinHostPort(cfg.Host, cfg.Port),
+			Path:     cfg.DBName,
+			RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+		}).String()
+	case config.DriverSQLite:
+		driverName, product, database = "sqlite", "SQLite", cfg.Path
+		// Transactions take the write lock up front, so concurrent writers
+		// wait for busy_timeout instead of failing on lock upgrades.
+		dsn = "file:" + cfg.Path + "?" + url.Values{
+			"_pragma": {"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"},
+			"_txlock": {"immediate"},
+		}.Encode()
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
 
-	db, err := sql.Open("mysql", dsn)
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
 	if err := db.Ping(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Println("MySQL connection established")
+	logger.Info(product+" connection established", "host", cfg.Host, "database", database)
 	return db, nil
 }